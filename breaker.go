@@ -0,0 +1,53 @@
+package main
+
+import (
+	"log/slog"
+	"time"
+)
+
+// breaker backs off the daemon interval while the scrapes keep failing, so
+// a jolokia agent that is down is not hammered and the logs not flooded.
+type breaker struct {
+	// threshold is how many consecutive failures open the breaker, zero
+	// disabling it.
+	threshold   int
+	maxInterval time.Duration
+	failures    int
+}
+
+func (b *breaker) isOpen() bool {
+	return b.threshold > 0 && b.failures >= b.threshold
+}
+
+// next records the outcome of a scrape and returns how long to wait until
+// the next one. Once open, the interval doubles on every failure up to
+// maxInterval, and the first success restores it.
+func (b *breaker) next(interval time.Duration, err error) time.Duration {
+	if err == nil {
+		if b.isOpen() {
+			slog.Info("Scrape succeeded, closing the circuit breaker", "failures", b.failures)
+		}
+		b.failures = 0
+		return interval
+	}
+
+	b.failures++
+	if !b.isOpen() {
+		slog.Error("Scrape failed", "error", err)
+		return interval
+	}
+	if b.failures == b.threshold {
+		slog.Error("Scrape failed repeatedly, opening the circuit breaker", "failures", b.failures, "error", err)
+	} else {
+		slog.Debug("Scrape failed while the circuit breaker is open", "failures", b.failures, "error", err)
+	}
+
+	delay := interval
+	for i := b.threshold; i <= b.failures && delay < b.maxInterval; i++ {
+		delay *= 2
+	}
+	if delay > b.maxInterval {
+		delay = b.maxInterval
+	}
+	return delay
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBreakerBackoff(t *testing.T) {
+	failed := errors.New("connection refused")
+	steps := []struct {
+		err  error
+		want time.Duration
+	}{
+		{failed, 10 * time.Second},
+		{failed, 10 * time.Second},
+		{failed, 20 * time.Second},
+		{failed, 40 * time.Second},
+		{failed, 80 * time.Second},
+		{failed, 2 * time.Minute},
+		{failed, 2 * time.Minute},
+		{nil, 10 * time.Second},
+		{failed, 10 * time.Second},
+		{nil, 10 * time.Second},
+	}
+
+	logs := &bytes.Buffer{}
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(logs, nil)))
+	defer slog.SetDefault(previous)
+
+	b := &breaker{threshold: 3, maxInterval: 2 * time.Minute}
+	for i, step := range steps {
+		if got := b.next(10*time.Second, step.err); got != step.want {
+			t.Errorf("scrape %d: next() = %s, want %s", i+1, got, step.want)
+		}
+	}
+
+	// The state changes are logged once each, not every failed attempt.
+	for msg, want := range map[string]int{
+		"opening the circuit breaker": 1,
+		"closing the circuit breaker": 1,
+		"msg=\"Scrape failed\"":       3,
+	} {
+		if got := strings.Count(logs.String(), msg); got != want {
+			t.Errorf("logged %q %d times, want %d; logs:\n%s", msg, got, want, logs)
+		}
+	}
+}
+
+func TestBreakerDisabled(t *testing.T) {
+	b := &breaker{maxInterval: time.Minute}
+	for i := 0; i < 10; i++ {
+		if got := b.next(10*time.Second, errors.New("connection refused")); got != 10*time.Second {
+			t.Fatalf("scrape %d: next() = %s, want the interval kept", i+1, got)
+		}
+	}
+}
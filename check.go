@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/CrossEngage/cassandra-keyspaces-checker/internal/checker"
+)
+
+// runCheck scrapes the jolokia agents once and prints a human readable
+// summary of what would be emitted from each, exiting non-zero if every
+// scrape fails.
+func runCheck(client *http.Client, cfg checker.Config) {
+	errs := []error{}
+	unknown := false
+	for i, result := range checker.FetchAll(context.Background(), client, cfg) {
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Printf("Jolokia URL:              %s\n", result.URL)
+		if result.Err != nil {
+			fmt.Printf("Error:                    %s\n", result.Err)
+			errs = append(errs, result.Err)
+			continue
+		}
+		if version, err := checker.ProbeVersion(context.Background(), client, result.URL, cfg); err == nil {
+			fmt.Printf("Jolokia agent:            %s (protocol %s)\n", version.Agent, version.Protocol)
+		}
+		stats := checker.Summarize(result.Response, cfg)
+		fmt.Printf("Metrics fetched:          %d\n", stats.Fetched)
+		fmt.Printf("Skipped by metric name:   %d\n", stats.SkippedByMetric)
+		fmt.Printf("Skipped by keyspace:      %d\n", stats.SkippedByKeyspace)
+		fmt.Printf("Skipped by table:         %d\n", stats.SkippedByTable)
+		fmt.Printf("Skipped by sampling:      %d\n", stats.SkippedBySampling)
+		fmt.Printf("Skipped for only zeros:   %d\n", stats.SkippedByZeros)
+		fmt.Printf("Skipped below min value:  %d\n", stats.SkippedByMinValue)
+		fmt.Printf("Series to emit:           %d\n", stats.Emitted)
+		fmt.Printf("Scrape duration:          %s\n", result.Duration)
+		if cfg.WarnUnknownSkips {
+			names := checker.UnknownNames([]*checker.Response{result.Response}, cfg)
+			if len(names) > 0 {
+				fmt.Printf("Unknown filtered names:   %s\n", strings.Join(names, ", "))
+				unknown = true
+			}
+		}
+	}
+	if len(errs) == len(cfg.JolokiaURLs) {
+		err := errors.Join(errs...)
+		fatal(exitCode(err), "Scrape failed", "error", err)
+	}
+	if unknown {
+		fatal(exitConfig, "Some --skip or --include names match no metric")
+	}
+}
+
+// runListMetrics scrapes the jolokia agents once and prints the names of the
+// metrics they expose, grouped by MBean type, so --skip and --include can be
+// written without guessing. Name filters are ignored.
+func runListMetrics(client *http.Client, cfg checker.Config) {
+	cfg.Bulk = false
+
+	resps := []*checker.Response{}
+	errs := []error{}
+	for _, result := range checker.FetchAll(context.Background(), client, cfg) {
+		if result.Err != nil {
+			slog.Error("Scrape failed", "url", result.URL.String(), "error", result.Err)
+			errs = append(errs, result.Err)
+			continue
+		}
+		resps = append(resps, result.Response)
+	}
+	if len(resps) == 0 {
+		fatal(exitCode(errors.Join(errs...)), "Scrape failed", "error", errors.Join(errs...))
+	}
+
+	names := checker.MetricNames(resps)
+	mbeanTypes := make([]string, 0, len(names))
+	for mbeanType := range names {
+		mbeanTypes = append(mbeanTypes, mbeanType)
+	}
+	sort.Strings(mbeanTypes)
+
+	for i, mbeanType := range mbeanTypes {
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Printf("%s (%d metrics):\n", mbeanType, len(names[mbeanType]))
+		for _, name := range names[mbeanType] {
+			fmt.Printf("  %s\n", name)
+		}
+	}
+}
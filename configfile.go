@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// configFileFromArgs finds the value of --config in args, before kingpin
+// parses them, so the file can provide the flag defaults.
+func configFileFromArgs(args []string) string {
+	for i, arg := range args {
+		if arg == "--config" && i+1 < len(args) {
+			return args[i+1]
+		}
+		if strings.HasPrefix(arg, "--config=") {
+			return strings.TrimPrefix(arg, "--config=")
+		}
+	}
+	return ""
+}
+
+// loadConfigFile reads a YAML file whose keys are flag names, with dashes
+// or underscores, and uses its values as the flag defaults. Flags given on
+// the command line still take precedence.
+func loadConfigFile(filename string) error {
+	values, err := readConfigFile(filename)
+	if err != nil {
+		return err
+	}
+	for name, defaults := range values {
+		app.GetFlag(name).Default(defaults...)
+	}
+	return nil
+}
+
+// readConfigFile returns the flag values set in a config file, by flag name.
+func readConfigFile(filename string) (map[string][]string, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	values := map[string]interface{}{}
+	if err := yaml.Unmarshal(data, &values); err != nil {
+		return nil, fmt.Errorf("could not parse %s: %s", filename, err)
+	}
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	flags := map[string][]string{}
+	for _, key := range keys {
+		name := strings.Replace(key, "_", "-", -1)
+		if app.GetFlag(name) == nil || key == "config" {
+			return nil, fmt.Errorf("unknown key `%s` in %s", key, filename)
+		}
+		flagValues, err := configValues(values[key])
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for `%s` in %s: %s", key, filename, err)
+		}
+		flags[name] = flagValues
+	}
+	return flags, nil
+}
+
+// configValues converts a YAML value into flag values: lists become
+// repeated values and mappings become key=value pairs.
+func configValues(value interface{}) ([]string, error) {
+	switch v := value.(type) {
+	case []interface{}:
+		values := []string{}
+		for _, item := range v {
+			itemValues, err := configValues(item)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, itemValues...)
+		}
+		return values, nil
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		values := []string{}
+		for _, key := range keys {
+			values = append(values, fmt.Sprintf("%s=%v", key, v[key]))
+		}
+		return values, nil
+	case nil:
+		return nil, fmt.Errorf("missing value")
+	}
+	return []string{fmt.Sprint(value)}, nil
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writeConfig writes a config file with content, returning its name.
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	filename := filepath.Join(t.TempDir(), "checker.yaml")
+	if err := os.WriteFile(filename, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return filename
+}
+
+func TestReadConfigFile(t *testing.T) {
+	filename := writeConfig(t, `
+jolokia: http://cassandra:8778/jolokia
+skip:
+  - BloomFilterFalseRatio
+  - SnapshotsSize
+skip_zeros: true
+timeout: 3s
+tag:
+  dc: eu1
+  rack: r1
+`)
+	got, err := readConfigFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][]string{
+		"jolokia":    {"http://cassandra:8778/jolokia"},
+		"skip":       {"BloomFilterFalseRatio", "SnapshotsSize"},
+		"skip-zeros": {"true"},
+		"timeout":    {"3s"},
+		"tag":        {"dc=eu1", "rack=r1"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("readConfigFile() = %v, want %v", got, want)
+	}
+}
+
+func TestReadConfigFileErrors(t *testing.T) {
+	tests := []struct {
+		name, content, want string
+	}{
+		{"unknown key", "skipp: ReadLatency\n", "unknown key `skipp`"},
+		{"config key", "config: other.yaml\n", "unknown key `config`"},
+		{"missing value", "skip:\n", "invalid value for `skip`"},
+		{"invalid yaml", "skip: [\n", "could not parse"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := readConfigFile(writeConfig(t, tt.content))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("readConfigFile() error = %v, want one containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfigFileFromArgs(t *testing.T) {
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"--config", "a.yaml"}, "a.yaml"},
+		{[]string{"--stderr", "--config=b.yaml"}, "b.yaml"},
+		{[]string{"--config"}, ""},
+		{[]string{"--skip", "x"}, ""},
+	}
+	for _, tt := range tests {
+		if got := configFileFromArgs(tt.args); got != tt.want {
+			t.Errorf("configFileFromArgs(%q) = %q, want %q", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestConfigFileEffectiveConfig(t *testing.T) {
+	filename := writeConfig(t, "jolokia: "+stubJolokia(t)+"\nmeasurement: ckc\nkeyspace: app\ninclude: [ReadLatency, WriteLatency]\n")
+	tests := []struct {
+		name string
+		args []string
+		want []string
+	}{
+		{"file only", nil, []string{"app/events/ReadLatency", "app/users/ReadLatency", "app/users/WriteLatency"}},
+		{"flag over file", []string{"--include", "LiveDiskSpaceUsed"}, []string{"app/users/LiveDiskSpaceUsed"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, stdout, stderr := runMain(t, append([]string{"--stderr", "--config", filename}, tt.args...)...)
+			if code != 0 {
+				t.Fatalf("exit code = %d, want 0; stderr: %s", code, stderr)
+			}
+			if got := collected(stdout); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("collected %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
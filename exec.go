@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/CrossEngage/cassandra-keyspaces-checker/internal/checker"
+)
+
+// runExec invokes the --exec operation, given as <mbean>/<operation>[/args],
+// on every jolokia agent and prints its result, exiting non-zero if any of
+// them fails.
+func runExec(client *http.Client, cfg checker.Config) {
+	parts := strings.Split(*execOperation, "/")
+	if len(parts) < 2 || parts[0] == "" || parts[1] == "" {
+		fatal(exitConfig, "Invalid --exec, expected <mbean>/<operation>[/args]", "exec", *execOperation)
+	}
+
+	errs := []error{}
+	for i, baseURL := range cfg.JolokiaURLs {
+		if i > 0 {
+			fmt.Println()
+		}
+		if len(cfg.JolokiaURLs) > 1 {
+			fmt.Printf("Jolokia URL: %s\n", baseURL)
+		}
+
+		start := time.Now()
+		value, err := checker.Exec(context.Background(), client, baseURL, cfg, parts[0], parts[1], parts[2:])
+		if err != nil {
+			slog.Error("Exec failed", "url", baseURL.String(), "error", err)
+			errs = append(errs, err)
+			continue
+		}
+		slog.Info("Exec done", "url", baseURL.String(), "operation", parts[1], "duration", time.Since(start))
+
+		result, err := json.MarshalIndent(value, "", "  ")
+		if err != nil {
+			fatal(exitFailure, "Could not print the exec result", "error", err)
+		}
+		fmt.Println(string(result))
+	}
+	if len(errs) > 0 {
+		os.Exit(exitCode(errors.Join(errs...)))
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestExecFlag(t *testing.T) {
+	tests := []struct {
+		name       string
+		exec       string
+		response   string
+		wantCode   int
+		wantStdout string
+	}{
+		{"result", "org.apache.cassandra.db:type=StorageService/getKeyspaces", `{"status": 200, "value": ["app", "system"]}`,
+			0, "[\n  \"app\",\n  \"system\"\n]\n"},
+		{"jolokia error", "org.apache.cassandra.db:type=StorageService/forceKeyspaceFlush/app", `{"status": 404, "error": "not found"}`,
+			exitResponse, ""},
+		{"no operation", "org.apache.cassandra.db:type=StorageService", `{"status": 200, "value": null}`,
+			exitConfig, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				// Only the exec request is posted, no metrics are read.
+				if r.Method != http.MethodPost {
+					t.Errorf("%s %s, want only the exec POST", r.Method, r.URL)
+				}
+				io.WriteString(w, tt.response)
+			}))
+			defer server.Close()
+
+			code, stdout, stderr := runMain(t, "--stderr", "--jolokia", server.URL+"/jolokia", "--exec", tt.exec)
+			if code != tt.wantCode {
+				t.Fatalf("exit code = %d, want %d; stderr: %s", code, tt.wantCode, stderr)
+			}
+			if stdout != tt.wantStdout {
+				t.Errorf("stdout = %q, want %q", stdout, tt.wantStdout)
+			}
+		})
+	}
+}
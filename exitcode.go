@@ -0,0 +1,35 @@
+package main
+
+import (
+	"errors"
+
+	"github.com/CrossEngage/cassandra-keyspaces-checker/internal/checker"
+)
+
+// Exit codes, so wrappers can tell the failures apart. They are documented
+// in the README.
+const (
+	exitFailure    = 1
+	exitNetwork    = 2
+	exitHTTPStatus = 3
+	exitResponse   = 4
+	exitConfig     = 5
+)
+
+// exitCode maps a scrape error to the exit code reporting it.
+func exitCode(err error) int {
+	var (
+		networkErr  *checker.NetworkError
+		statusErr   *checker.StatusError
+		responseErr *checker.ResponseError
+	)
+	switch {
+	case errors.As(err, &networkErr):
+		return exitNetwork
+	case errors.As(err, &statusErr):
+		return exitHTTPStatus
+	case errors.As(err, &responseErr):
+		return exitResponse
+	}
+	return exitFailure
+}
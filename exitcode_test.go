@@ -0,0 +1,144 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/CrossEngage/cassandra-keyspaces-checker/internal/checker"
+)
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"network", &checker.NetworkError{Err: errors.New("connection refused")}, exitNetwork},
+		{"wrapped network", fmt.Errorf("scrape: %w", &checker.NetworkError{Err: errors.New("connection refused")}), exitNetwork},
+		{"http status", &checker.StatusError{StatusCode: 500, Status: "500 Internal Server Error"}, exitHTTPStatus},
+		{"response", &checker.ResponseError{Err: errors.New("invalid character")}, exitResponse},
+		{"other", errors.New("boom"), exitFailure},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := exitCode(tt.err); got != tt.want {
+				t.Errorf("exitCode() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+// closedURL returns the URL of a jolokia agent refusing connections.
+func closedURL(t *testing.T) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+	return "http://" + addr + "/jolokia"
+}
+
+// answeringURL returns the URL of a jolokia agent answering every request
+// with status and body.
+func answeringURL(t *testing.T, status int, body string) string {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		io.WriteString(w, body)
+	}))
+	t.Cleanup(server.Close)
+	return server.URL + "/jolokia"
+}
+
+func TestFailureExitCodes(t *testing.T) {
+	tests := []struct {
+		name string
+		url  func(t *testing.T) string
+		want int
+	}{
+		{"unreachable", closedURL, exitNetwork},
+		{"http status", func(t *testing.T) string {
+			return answeringURL(t, http.StatusInternalServerError, `{"status": 500, "error": "boom"}`)
+		}, exitHTTPStatus},
+		{"invalid json", func(t *testing.T) string {
+			return answeringURL(t, http.StatusOK, `{"status": 200, "value": `)
+		}, exitResponse},
+		{"jolokia status", func(t *testing.T) string {
+			return answeringURL(t, http.StatusOK, `{"status": 404, "error": "javax.management.InstanceNotFoundException"}`)
+		}, exitResponse},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, stderr := runMain(t, "--stderr", "--jolokia", tt.url(t))
+			if code != tt.want {
+				t.Errorf("exit code = %d, want %d; stderr: %s", code, tt.want, stderr)
+			}
+		})
+	}
+}
+
+func TestConfigExitCode(t *testing.T) {
+	if code, _, stderr := runMain(t, "--stderr", "--config", "/nonexistent/checker.yaml"); code != exitConfig {
+		t.Errorf("exit code = %d, want %d; stderr: %s", code, exitConfig, stderr)
+	}
+}
+
+func TestHeartbeatEmission(t *testing.T) {
+	tests := []struct {
+		name     string
+		url      func(t *testing.T) string
+		wantCode int
+		wantUp   string
+	}{
+		{"success", stubJolokia, 0, "up=1i"},
+		{"failure", closedURL, exitNetwork, "up=0i"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, stdout, stderr := runMain(t, "--stderr", "--jolokia", tt.url(t))
+			if code != tt.wantCode {
+				t.Errorf("exit code = %d, want %d; stderr: %s", code, tt.wantCode, stderr)
+			}
+			if !strings.Contains(stdout, "cassandra_keyspaces_checker,") || !strings.Contains(stdout, tt.wantUp) {
+				t.Errorf("stdout = %q, want a heartbeat with %s", stdout, tt.wantUp)
+			}
+		})
+	}
+}
+
+func TestStrictExitCode(t *testing.T) {
+	body := `{"status": 200, "timestamp": 1700000000, "value": {"org.apache.cassandra.metrics:keyspace=app,name=ReadLatency,scope=users,type=ColumnFamily": {"Count": 3, "GCStats": {"CollectionCount": 5}}}}`
+	tests := []struct {
+		name     string
+		args     []string
+		wantCode int
+		want     string
+		wantUp   string
+	}{
+		{"lenient", nil, 0, "", "up=1i"},
+		{"strict", []string{"--strict"}, exitResponse, "in field GCStats of keyspace=app,name=ReadLatency,scope=users,type=ColumnFamily", "up=0i"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := append([]string{"--stderr", "--jolokia", answeringURL(t, http.StatusOK, body)}, tt.args...)
+			code, stdout, stderr := runMain(t, args...)
+			if code != tt.wantCode {
+				t.Fatalf("exit code = %d, want %d; stderr: %s", code, tt.wantCode, stderr)
+			}
+			if !strings.Contains(stderr, tt.want) {
+				t.Errorf("stderr = %q, want %q", stderr, tt.want)
+			}
+			if !strings.Contains(stdout, tt.wantUp) {
+				t.Errorf("stdout = %q, want the heartbeat with %s", stdout, tt.wantUp)
+			}
+		})
+	}
+}
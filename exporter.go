@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/CrossEngage/cassandra-keyspaces-checker/internal/checker"
+)
+
+// serveMetrics runs a prometheus exporter on addr, scraping the jolokia agents
+// on every request to /metrics, along with the /healthz and /ready probes.
+// Once ctx is cancelled, the requests in progress get up to shutdownTimeout
+// to complete.
+func serveMetrics(ctx context.Context, addr string, client *http.Client, cfg checker.Config, shutdownTimeout time.Duration) error {
+	cfg.OutputFormat = "prometheus"
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		lines, err := checker.Scrape(r.Context(), client, cfg)
+		if err != nil {
+			slog.Error("Scrape failed", "error", err)
+			http.Error(w, "jolokia scrape failed: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		scraped.Store(true)
+		lines = append(lines,
+			"# TYPE cassandra_keyspaces_checker_scrape_duration_seconds gauge",
+			fmt.Sprintf("cassandra_keyspaces_checker_scrape_duration_seconds %f", time.Since(start).Seconds()))
+
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		fmt.Fprintln(w, strings.Join(lines, "\n"))
+	})
+
+	handleHealth(mux)
+
+	server := &http.Server{Addr: addr, Handler: mux}
+	shutdown := make(chan error, 1)
+	go func() {
+		<-ctx.Done()
+		slog.Info("Received signal, shutting down the HTTP server")
+		sctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		shutdown <- server.Shutdown(sctx)
+	}()
+	if err := server.ListenAndServe(); err != http.ErrServerClosed {
+		return err
+	}
+	return <-shutdown
+}
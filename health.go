@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"sync/atomic"
+)
+
+// scraped is set by the first successful scrape, from which on /ready
+// reports the checker as ready.
+var scraped atomic.Bool
+
+// handleHealth adds to mux /healthz, answering 200 as long as the process
+// runs, and /ready, answering 503 until a scrape succeeded and 200 after.
+func handleHealth(mux *http.ServeMux) {
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "ok")
+	})
+	mux.HandleFunc("/ready", func(w http.ResponseWriter, r *http.Request) {
+		if !scraped.Load() {
+			http.Error(w, "no successful scrape yet", http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprintln(w, "ready")
+	})
+}
+
+// serveHealth serves /healthz and /ready on addr in the background, for the
+// daemon mode. Only the listening can fail, the serving errors are logged.
+func serveHealth(addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	mux := http.NewServeMux()
+	handleHealth(mux)
+	go func() {
+		slog.Error("Health server failed", "error", http.Serve(listener, mux))
+	}()
+	return nil
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHandleHealth(t *testing.T) {
+	t.Cleanup(func() { scraped.Store(false) })
+	mux := http.NewServeMux()
+	handleHealth(mux)
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	tests := []struct {
+		name    string
+		scraped bool
+		path    string
+		want    int
+	}{
+		{"healthz before a scrape", false, "/healthz", http.StatusOK},
+		{"ready before a scrape", false, "/ready", http.StatusServiceUnavailable},
+		{"healthz after a scrape", true, "/healthz", http.StatusOK},
+		{"ready after a scrape", true, "/ready", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scraped.Store(tt.scraped)
+			resp, err := http.Get(server.URL + tt.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.want {
+				t.Errorf("GET %s = %d, want %d", tt.path, resp.StatusCode, tt.want)
+			}
+		})
+	}
+}
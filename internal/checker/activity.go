@@ -0,0 +1,87 @@
+package checker
+
+import (
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// activityMetrics are the table metrics whose Count tells the reads and
+// writes a table served.
+var activityMetrics = []string{"ReadLatency", "WriteLatency"}
+
+// Activity remembers the reads plus writes of every table on the previous
+// scrape, so the tables without any since can be left out. It is safe for
+// concurrent use.
+type Activity struct {
+	mu   sync.Mutex
+	prev map[string]float64
+}
+
+// NewActivity returns an empty Activity, under which every table is active
+// on the first scrape.
+func NewActivity() *Activity {
+	return &Activity{prev: map[string]float64{}}
+}
+
+// dropInactive removes the metrics of the tables whose read and write
+// counts did not change since the previous scrape. Tables without the
+// counts, such as when ReadLatency and WriteLatency are not collected, are
+// kept, as their activity is unknown rather than none.
+func (a *Activity) dropInactive(metrics []metric, stats []Stats) []metric {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	counts := map[string]float64{}
+	for _, m := range metrics {
+		if scopeTags[m.mbeanType] != "cf" || !contains(activityMetrics, m.name) {
+			continue
+		}
+		for _, f := range m.fields {
+			if f.key != "Count" {
+				continue
+			}
+			if value, ok := toFloat(f.value); ok {
+				counts[tableKey(m)] += value
+			}
+		}
+	}
+
+	inactive := map[string]bool{}
+	for key, count := range counts {
+		prev, seen := a.prev[key]
+		a.prev[key] = count
+		if seen && prev == count {
+			inactive[key] = true
+		}
+	}
+	if len(inactive) == 0 {
+		return metrics
+	}
+
+	kept := metrics[:0]
+	for _, m := range metrics {
+		if scopeTags[m.mbeanType] == "cf" && inactive[tableKey(m)] {
+			slog.Debug("Skipping metric of an inactive table", "key_path", m.keyPath)
+			stats[m.source].SkippedInactive++
+			stats[m.source].Emitted--
+			continue
+		}
+		kept = append(kept, m)
+	}
+	return kept
+}
+
+// tableKey identifies the table of m, and its node, by the tags of m other
+// than the metric and the MBean.
+func tableKey(m metric) string {
+	parts := []string{}
+	for _, t := range m.tags {
+		if t.key != "metric" && t.key != "mbean" {
+			parts = append(parts, t.key+"="+t.value)
+		}
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ",")
+}
@@ -0,0 +1,65 @@
+package checker
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestRenderActiveOnly(t *testing.T) {
+	cfg := Config{Measurement: "ckc", Hostname: "node1", Activity: NewActivity()}
+	value := func(usersReads, ordersReads string) string {
+		return `{
+			"org.apache.cassandra.metrics:keyspace=app,name=ReadLatency,scope=users,type=ColumnFamily": {"Count": ` + usersReads + `},
+			"org.apache.cassandra.metrics:keyspace=app,name=WriteLatency,scope=users,type=ColumnFamily": {"Count": 2},
+			"org.apache.cassandra.metrics:keyspace=app,name=ReadLatency,scope=orders,type=ColumnFamily": {"Count": ` + ordersReads + `},
+			"org.apache.cassandra.metrics:keyspace=app,name=LiveDiskSpaceUsed,scope=events,type=ColumnFamily": {"Count": 7}
+		}`
+	}
+	tests := []struct {
+		name         string
+		value        string
+		want         []string
+		wantInactive int
+	}{
+		{"first scrape", value("3", "5"), []string{
+			"ckc,cf=events,host=node1,keyspace=app,metric=LiveDiskSpaceUsed Count=7i 1700000000000000000",
+			"ckc,cf=orders,host=node1,keyspace=app,metric=ReadLatency Count=5i 1700000000000000000",
+			"ckc,cf=users,host=node1,keyspace=app,metric=ReadLatency Count=3i 1700000000000000000",
+			"ckc,cf=users,host=node1,keyspace=app,metric=WriteLatency Count=2i 1700000000000000000",
+		}, 0},
+		// orders served nothing and is dropped, events has no counts and is
+		// kept.
+		{"second scrape", value("4", "5"), []string{
+			"ckc,cf=events,host=node1,keyspace=app,metric=LiveDiskSpaceUsed Count=7i 1700000000000000000",
+			"ckc,cf=users,host=node1,keyspace=app,metric=ReadLatency Count=4i 1700000000000000000",
+			"ckc,cf=users,host=node1,keyspace=app,metric=WriteLatency Count=2i 1700000000000000000",
+		}, 1},
+		{"all idle", value("4", "5"), []string{
+			"ckc,cf=events,host=node1,keyspace=app,metric=LiveDiskSpaceUsed Count=7i 1700000000000000000",
+		}, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &Response{}
+			if err := json.Unmarshal([]byte(`{"status": 200, "timestamp": 1700000000, "value": `+tt.value+`}`), resp); err != nil {
+				t.Fatal(err)
+			}
+			lines, stats, err := RenderWithStats(resp, cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sort.Strings(lines)
+			if strings.Join(lines, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("Render() = %q, want %q", lines, tt.want)
+			}
+			if stats.SkippedInactive != tt.wantInactive {
+				t.Errorf("SkippedInactive = %d, want %d", stats.SkippedInactive, tt.wantInactive)
+			}
+			if stats.Emitted != len(tt.want) {
+				t.Errorf("Emitted = %d, want %d", stats.Emitted, len(tt.want))
+			}
+		})
+	}
+}
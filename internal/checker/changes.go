@@ -0,0 +1,66 @@
+package checker
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Changes remembers the fields last emitted for every series, so that
+// series unchanged since the previous scrape can be left out. A series is
+// emitted again once its last emission is older than the interval, so
+// static series do not go silent. It is safe for concurrent use.
+type Changes struct {
+	mu       sync.Mutex
+	interval time.Duration
+	last     map[string]emission
+}
+
+type emission struct {
+	fields string
+	at     time.Time
+}
+
+// NewChanges returns an empty Changes forcing emissions every interval, or
+// never if it is zero.
+func NewChanges(interval time.Duration) *Changes {
+	return &Changes{interval: interval, last: map[string]emission{}}
+}
+
+// unchanged tells whether m has the same fields as last emitted, within the
+// interval.
+func (c *Changes) unchanged(m metric, now time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	last, seen := c.last[m.seriesKey()]
+	return seen && last.fields == fmt.Sprint(m.fields) && (c.interval == 0 || now.Sub(last.at) < c.interval)
+}
+
+// dropUnchanged removes the metrics unchanged since last emitted.
+func (c *Changes) dropUnchanged(metrics []metric, stats []Stats, now time.Time) []metric {
+	kept := metrics[:0]
+	for _, m := range metrics {
+		if len(m.fields) > 0 && c.unchanged(m, now) {
+			stats[m.source].SkippedUnchanged++
+			stats[m.source].Emitted--
+			continue
+		}
+		kept = append(kept, m)
+	}
+	return kept
+}
+
+// record remembers the metrics as emitted at now. Only the series actually
+// emitted must be recorded, or those left out would be held back as
+// unchanged.
+func (c *Changes) record(metrics []metric, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, m := range metrics {
+		if len(m.fields) > 0 {
+			c.last[m.seriesKey()] = emission{fmt.Sprint(m.fields), now}
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package checker
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestChangesUnchanged(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	series := func(host string, count int64) metric {
+		return metric{mbeanType: "ColumnFamily", tags: []tag{{"host", host}}, fields: []field{{"Count", count}}}
+	}
+	tests := []struct {
+		name     string
+		interval time.Duration
+		first    metric
+		second   metric
+		after    time.Duration
+		want     bool
+	}{
+		{"unchanged", time.Minute, series("node1", 1), series("node1", 1), 10 * time.Second, true},
+		{"changed", time.Minute, series("node1", 1), series("node1", 2), 10 * time.Second, false},
+		{"other series", time.Minute, series("node1", 1), series("node2", 1), 10 * time.Second, false},
+		{"heartbeat forced", time.Minute, series("node1", 1), series("node1", 1), time.Minute, false},
+		{"no heartbeat", 0, series("node1", 1), series("node1", 1), time.Hour, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewChanges(tt.interval)
+			if c.unchanged(tt.first, start) {
+				t.Fatal("unchanged() = true for a series never emitted")
+			}
+			c.record([]metric{tt.first}, start)
+			if got := c.unchanged(tt.second, start.Add(tt.after)); got != tt.want {
+				t.Errorf("unchanged() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderOnlyChanged(t *testing.T) {
+	cfg := Config{Measurement: "ckc", Hostname: "node1", Changes: NewChanges(time.Hour)}
+	value := func(count string) string {
+		return `{"org.apache.cassandra.metrics:keyspace=app,name=ReadLatency,scope=users,type=ColumnFamily": {"Count": ` + count + `}}`
+	}
+	tests := []struct {
+		name  string
+		count string
+		want  []string
+	}{
+		{"first", "3", []string{"ckc,cf=users,host=node1,keyspace=app,metric=ReadLatency Count=3i 1700000000000000000"}},
+		{"unchanged", "3", nil},
+		{"changed", "4", []string{"ckc,cf=users,host=node1,keyspace=app,metric=ReadLatency Count=4i 1700000000000000000"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lines, err := renderValue(t, value(tt.count), cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Join(lines, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("Render() = %q, want %q", lines, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderOnlyChangedMaxSeries(t *testing.T) {
+	value := `{
+		"org.apache.cassandra.metrics:keyspace=app,name=ReadLatency,scope=a,type=ColumnFamily": {"Count": 1},
+		"org.apache.cassandra.metrics:keyspace=app,name=ReadLatency,scope=b,type=ColumnFamily": {"Count": 2},
+		"org.apache.cassandra.metrics:keyspace=app,name=ReadLatency,scope=c,type=ColumnFamily": {"Count": 3}
+	}`
+	tests := []struct {
+		name      string
+		maxSeries int
+		want      [][]string
+	}{
+		// The series above the cap was never emitted, so it is not held
+		// back as unchanged once the others are.
+		{"capped", 2, [][]string{{
+			"ckc,cf=a,host=node1,keyspace=app,metric=ReadLatency Count=1i 1700000000000000000",
+			"ckc,cf=b,host=node1,keyspace=app,metric=ReadLatency Count=2i 1700000000000000000",
+		}, {
+			"ckc,cf=c,host=node1,keyspace=app,metric=ReadLatency Count=3i 1700000000000000000",
+		}, nil}},
+		{"not capped", 3, [][]string{{
+			"ckc,cf=a,host=node1,keyspace=app,metric=ReadLatency Count=1i 1700000000000000000",
+			"ckc,cf=b,host=node1,keyspace=app,metric=ReadLatency Count=2i 1700000000000000000",
+			"ckc,cf=c,host=node1,keyspace=app,metric=ReadLatency Count=3i 1700000000000000000",
+		}, nil, nil}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Config{Measurement: "ckc", Hostname: "node1", Changes: NewChanges(time.Hour), MaxSeries: tt.maxSeries}
+			for i, want := range tt.want {
+				lines, err := renderValue(t, value, cfg)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if strings.Join(lines, "\n") != strings.Join(want, "\n") {
+					t.Errorf("scrape %d: Render() = %q, want %q", i, lines, want)
+				}
+			}
+		})
+	}
+}
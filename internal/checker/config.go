@@ -0,0 +1,113 @@
+// Package checker fetches Cassandra table metrics from a jolokia agent and
+// renders them in the supported output formats.
+package checker
+
+import (
+	"net/url"
+	"regexp"
+	"time"
+)
+
+// Config holds everything needed to fetch and render the metrics. Its fields
+// mirror the command line flags.
+type Config struct {
+	Name        string
+	Measurement string
+	Hostname    string
+	HostTagKey  string
+	NoHostTag   bool
+	Tags        map[string]string
+	NodeTags    map[string]map[string]string
+	JolokiaURLs []*url.URL
+	Concurrency int
+	Workers     int
+	Proxy       *url.URL
+	// RequestID is sent as X-Request-ID, a random one per scrape when empty.
+	RequestID string
+
+	User     string
+	Password string
+
+	// TargetURL is the JMX service URL read through jolokia in proxy mode.
+	TargetURL      string
+	TargetUser     string
+	TargetPassword string
+
+	CACert             string
+	Cert               string
+	Key                string
+	InsecureSkipVerify bool
+
+	Timeout             time.Duration
+	DialTimeout         time.Duration
+	MaxIdleConnsPerHost int
+	IdleConnTimeout     time.Duration
+	Retries             int
+	RetryDelay          time.Duration
+	Bulk                bool
+	MBeanTypes          []string
+	MaxResponseBytes    int64
+	MaxDepth            int
+	MaxCollectionSize   int
+	MaxObjects          int
+	MaxSeries           int
+	FailOnEmpty         bool
+
+	OutputFormat    string
+	GraphitePrefix  string
+	JSONIndent      bool
+	NoIntegerSuffix bool
+	CompactFloats   bool
+	// TimestampPrecision is the unit of influx timestamps: ns, us, ms or s.
+	TimestampPrecision   string
+	SkipZeros            bool
+	MinValue             float64
+	KeepNaN              bool
+	BoolAsInt            bool
+	Strict               bool
+	Histograms           bool
+	PrometheusHistograms bool
+	FlattenComposite     bool
+	WarnDuplicates       bool
+	RollupKeyspace       bool
+	RollupOnly           bool
+	// Rollup maps the fields to roll up to their function, all numeric
+	// fields being rolled up when empty.
+	Rollup           map[string]string
+	EmitTypeTags     bool
+	EmitScrapeTiming bool
+	// TimestampSource is either jolokia or local, unless FixedTimestamp is
+	// set.
+	TimestampSource string
+	FixedTimestamp  time.Time
+	Rename          map[string]string
+	Scale           map[string]float64
+	NumericFields   []string
+	Rates           *Rates
+	Deltas          *Deltas
+	Changes         *Changes
+	Activity        *Activity
+
+	DefaultTags bool
+	// StripMetricPrefix is removed from the start of the metric tag.
+	StripMetricPrefix string
+	// EmitMBeanTag adds the full MBean name as the mbean tag, to trace a
+	// series back to its source.
+	EmitMBeanTag     bool
+	DropEmptyTags    bool
+	DefaultKeyspace  string
+	DefaultCF        string
+	Skip             []string
+	Include          []string
+	SkipRegex        []*regexp.Regexp
+	WarnUnknownSkips bool
+
+	Keyspaces           []string
+	SkipKeyspaces       []string
+	SkipSystemKeyspaces bool
+	Tables              []string
+	SkipTables          []string
+	Fields              []string
+	SkipFields          []string
+	SampleRate          float64
+}
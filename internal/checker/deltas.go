@@ -0,0 +1,47 @@
+package checker
+
+import "sync"
+
+// Deltas remembers the values of the previous scrape of the selected fields,
+// so their change can be emitted. It is safe for concurrent use.
+type Deltas struct {
+	mu     sync.Mutex
+	fields []string
+	prev   map[string]float64
+}
+
+// NewDeltas returns an empty Deltas for the given fields.
+func NewDeltas(fields []string) *Deltas {
+	return &Deltas{fields: fields, prev: map[string]float64{}}
+}
+
+// deltaFields returns a `<field>_delta` field for each selected field of m
+// whose previous value is known, its current value minus the previous one.
+func (d *Deltas) deltaFields(m metric) []field {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	fields := []field{}
+	for _, f := range m.fields {
+		if !contains(d.fields, f.key) {
+			continue
+		}
+		value, ok := toFloat(f.value)
+		if !ok {
+			continue
+		}
+
+		key := m.seriesKey() + " " + f.key
+		prev, seen := d.prev[key]
+		d.prev[key] = value
+		if !seen {
+			continue
+		}
+		if _, ok := f.value.(int64); ok {
+			fields = append(fields, field{f.key + "_delta", int64(value - prev)})
+		} else {
+			fields = append(fields, field{f.key + "_delta", value - prev})
+		}
+	}
+	return fields
+}
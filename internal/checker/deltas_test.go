@@ -0,0 +1,72 @@
+package checker
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// renderScrapes renders one scrape per count of ReadLatency, 10 seconds
+// apart, returning the fields of each.
+func renderScrapes(t *testing.T, cfg Config, counts ...int) []string {
+	t.Helper()
+	fields := []string{}
+	for i, count := range counts {
+		resp := &Response{}
+		body := fmt.Sprintf(`{"status": 200, "timestamp": %d, "value": {"org.apache.cassandra.metrics:keyspace=app,name=ReadLatency,scope=users,type=ColumnFamily": {"Count": %d, "Mean": 1.5}}}`,
+			1700000000+10*i, count)
+		if err := json.Unmarshal([]byte(body), resp); err != nil {
+			t.Fatal(err)
+		}
+		lines, err := Render(resp, cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(lines) != 1 {
+			t.Fatalf("Render() = %q, want one line", lines)
+		}
+		fields = append(fields, strings.Fields(lines[0])[1])
+	}
+	return fields
+}
+
+func TestRenderDeltas(t *testing.T) {
+	tests := []struct {
+		name   string
+		cfg    func() Config
+		counts []int
+		want   []string
+	}{
+		{"first scrape, positive and negative delta", func() Config { return Config{Deltas: NewDeltas([]string{"Count"})} }, []int{10, 15, 12}, []string{
+			"Count=10i,Mean=1.500000",
+			"Count=15i,Count_delta=5i,Mean=1.500000",
+			"Count=12i,Count_delta=-3i,Mean=1.500000",
+		}},
+		{"unchanged", func() Config { return Config{Deltas: NewDeltas([]string{"Count"})} }, []int{10, 10}, []string{
+			"Count=10i,Mean=1.500000",
+			"Count=10i,Count_delta=0i,Mean=1.500000",
+		}},
+		{"float field", func() Config { return Config{Deltas: NewDeltas([]string{"Mean"})} }, []int{10, 15}, []string{
+			"Count=10i,Mean=1.500000",
+			"Count=15i,Mean=1.500000,Mean_delta=0.000000",
+		}},
+		{"rates only", func() Config { return Config{Rates: NewRates()} }, []int{10, 15}, []string{
+			"Count=10i,Mean=1.500000",
+			"Count=15i,Count_rate=0.500000,Mean=1.500000",
+		}},
+		{"deltas and rates", func() Config { return Config{Rates: NewRates(), Deltas: NewDeltas([]string{"Count"})} }, []int{10, 15}, []string{
+			"Count=10i,Mean=1.500000",
+			"Count=15i,Count_delta=5i,Count_rate=0.500000,Mean=1.500000",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := tt.cfg()
+			cfg.Measurement, cfg.Hostname = "ckc", "node1"
+			if got := renderScrapes(t, cfg, tt.counts...); strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("fields = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package checker
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// clusterTagAttributes maps the StorageService attributes read by
+// DiscoverClusterTags to the tags holding them.
+var clusterTagAttributes = map[string]string{
+	"ReleaseVersion": "cassandra_version",
+	"ClusterName":    "cluster",
+}
+
+// topologyTagAttributes maps the EndpointSnitchInfo attributes read by
+// DiscoverTopologyTags to the tags holding them.
+var topologyTagAttributes = map[string]string{
+	"Datacenter": "dc",
+	"Rack":       "rack",
+}
+
+// DiscoverClusterTags reads the Cassandra version and cluster name of every
+// configured agent, returning them as tags by agent URL. Agents that cannot
+// be read are logged and get no tags.
+func DiscoverClusterTags(ctx context.Context, client *http.Client, cfg Config) map[string]map[string]string {
+	return discoverNodeTags(ctx, client, cfg, "org.apache.cassandra.db:type=StorageService", clusterTagAttributes)
+}
+
+// DiscoverTopologyTags works as DiscoverClusterTags for the data center and
+// rack of every agent, as seen by the endpoint snitch.
+func DiscoverTopologyTags(ctx context.Context, client *http.Client, cfg Config) map[string]map[string]string {
+	return discoverNodeTags(ctx, client, cfg, "org.apache.cassandra.db:type=EndpointSnitchInfo", topologyTagAttributes)
+}
+
+func discoverNodeTags(ctx context.Context, client *http.Client, cfg Config, mbean string, attributes map[string]string) map[string]map[string]string {
+	nodeTags := map[string]map[string]string{}
+	for _, baseURL := range cfg.JolokiaURLs {
+		tags, err := discoverTags(ctx, client, baseURL, cfg, mbean, attributes)
+		if err != nil {
+			slog.Warn("Could not discover the node tags", "url", baseURL.String(), "mbean", mbean, "error", err)
+			continue
+		}
+		nodeTags[baseURL.String()] = tags
+	}
+	return nodeTags
+}
+
+func discoverTags(ctx context.Context, client *http.Client, baseURL *url.URL, cfg Config, mbean string, attributes map[string]string) (map[string]string, error) {
+	if cfg.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.Timeout)
+		defer cancel()
+	}
+
+	names := []string{}
+	for attribute := range attributes {
+		names = append(names, attribute)
+	}
+	sort.Strings(names)
+	loc := baseURL.JoinPath("read", mbean, strings.Join(names, ","))
+	jsonResp := struct {
+		Status    int                    `json:"status"`
+		Error     string                 `json:"error"`
+		ErrorType string                 `json:"error_type"`
+		Value     map[string]interface{} `json:"value"`
+	}{}
+	if err := doRequest(ctx, client, http.MethodGet, loc, nil, cfg, &jsonResp); err != nil {
+		return nil, err
+	}
+	if jsonResp.Status != 200 || jsonResp.Error != "" {
+		return nil, fmt.Errorf("jolokia status %d: %s", jsonResp.Status, jolokiaMessage(jsonResp.ErrorType, jsonResp.Error))
+	}
+
+	tags := map[string]string{}
+	for attribute, key := range attributes {
+		if value, ok := jsonResp.Value[attribute].(string); ok && value != "" {
+			tags[key] = value
+		}
+	}
+	return tags, nil
+}
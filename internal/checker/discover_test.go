@@ -0,0 +1,74 @@
+package checker
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+// mbeanAgent answers the reads of mbean with value and every other request
+// with a jolokia 404.
+func mbeanAgent(t *testing.T, mbean, value string) *url.URL {
+	t.Helper()
+	return stubAgent(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/jolokia/read/"+mbean {
+			io.WriteString(w, `{"status": 404, "error_type": "javax.management.InstanceNotFoundException", "error": "not found"}`)
+			return
+		}
+		io.WriteString(w, `{"status": 200, "timestamp": 1, "value": `+value+`}`)
+	})
+}
+
+func TestDiscoverClusterTags(t *testing.T) {
+	node1 := mbeanAgent(t, "org.apache.cassandra.db:type=StorageService/ClusterName,ReleaseVersion",
+		`{"ClusterName": "main", "ReleaseVersion": "4.1.3"}`)
+	node2 := mbeanAgent(t, "org.apache.cassandra.db:type=StorageService/ClusterName,ReleaseVersion",
+		`{"ClusterName": "main", "ReleaseVersion": ""}`)
+	down := mbeanAgent(t, "none", `{}`)
+
+	got := DiscoverClusterTags(context.Background(), http.DefaultClient, Config{JolokiaURLs: []*url.URL{node1, node2, down}})
+	want := map[string]map[string]string{
+		node1.String(): {"cassandra_version": "4.1.3", "cluster": "main"},
+		node2.String(): {"cluster": "main"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("DiscoverClusterTags() = %v, want %v", got, want)
+	}
+}
+
+func TestDiscoverTopologyTags(t *testing.T) {
+	snitch := "org.apache.cassandra.db:type=EndpointSnitchInfo/Datacenter,Rack"
+	node1 := mbeanAgent(t, snitch, `{"Datacenter": "eu-west", "Rack": "rack1"}`)
+	node2 := mbeanAgent(t, snitch, `{"Datacenter": "us-east"}`)
+	noSnitch := mbeanAgent(t, "none", `{}`)
+
+	got := DiscoverTopologyTags(context.Background(), http.DefaultClient, Config{JolokiaURLs: []*url.URL{node1, node2, noSnitch}})
+	want := map[string]map[string]string{
+		node1.String(): {"dc": "eu-west", "rack": "rack1"},
+		node2.String(): {"dc": "us-east"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("DiscoverTopologyTags() = %v, want %v", got, want)
+	}
+}
+
+func TestScrapeNodeTags(t *testing.T) {
+	baseURL := stubAgent(t, respondWith(`{"status": 200, "timestamp": 1700000000, "value": {"org.apache.cassandra.metrics:keyspace=app,name=ReadLatency,scope=users,type=ColumnFamily": {"Count": 3}}}`))
+	cfg := Config{
+		Measurement: "ckc",
+		Hostname:    "node1",
+		JolokiaURLs: []*url.URL{baseURL},
+		NodeTags:    map[string]map[string]string{baseURL.String(): {"cassandra_version": "4.1.3", "cluster": "main"}},
+	}
+	lines, err := Scrape(context.Background(), http.DefaultClient, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "ckc,cassandra_version=4.1.3,cf=users,cluster=main,host=node1,keyspace=app,metric=ReadLatency Count=3i 1700000000000000000"
+	if len(lines) != 2 || lines[0] != want {
+		t.Errorf("Scrape() = %q, want %q and the heartbeat", lines, want)
+	}
+}
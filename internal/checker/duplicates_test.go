@@ -0,0 +1,76 @@
+package checker
+
+import (
+	"bytes"
+	"log/slog"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// captureLogs routes the default logger to the returned buffer for the
+// duration of the test.
+func captureLogs(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(buf, nil)))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+	return buf
+}
+
+func TestRenderDuplicates(t *testing.T) {
+	// The same series, with the segments in another order.
+	value := `{
+		"org.apache.cassandra.metrics:keyspace=app,name=ReadLatency,scope=users,type=ColumnFamily": {"Count": 3, "Max": 9},
+		"org.apache.cassandra.metrics:type=ColumnFamily,scope=users,name=ReadLatency,keyspace=app": {"Count": 4, "Min": 1}
+	}`
+	tests := []struct {
+		name     string
+		cfg      Config
+		wantWarn bool
+	}{
+		{"merged", Config{Measurement: "ckc", Hostname: "node1"}, false},
+		{"warned", Config{Measurement: "ckc", Hostname: "node1", WarnDuplicates: true}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logs := captureLogs(t)
+			lines, err := renderValue(t, value, tt.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(lines) != 1 || !strings.HasPrefix(lines[0], "ckc,cf=users,host=node1,keyspace=app,metric=ReadLatency Count=") ||
+				!strings.Contains(lines[0], ",Max=9i,Min=1i ") {
+				t.Errorf("Render() = %q, want one line with the fields of both MBeans", lines)
+			}
+			warned := strings.Contains(logs.String(), "MBeans map to the same series")
+			if warned != tt.wantWarn {
+				t.Errorf("warned = %v, want %v; logs: %s", warned, tt.wantWarn, logs)
+			}
+			if warned && (!strings.Contains(logs.String(), "keyspace=app,name=ReadLatency,scope=users,type=ColumnFamily") ||
+				!strings.Contains(logs.String(), "type=ColumnFamily,scope=users,name=ReadLatency,keyspace=app")) {
+				t.Errorf("logs = %s, want both key paths", logs)
+			}
+		})
+	}
+}
+
+func TestMergeDuplicates(t *testing.T) {
+	metrics := []metric{
+		{mbeanType: "ColumnFamily", keyPath: "a", tags: []tag{{"keyspace", "app"}, {"cf", "users"}}, fields: []field{{"Count", 3}, {"Max", 9}}},
+		{mbeanType: "ColumnFamily", keyPath: "b", tags: []tag{{"cf", "users"}, {"keyspace", "app"}}, fields: []field{{"Count", 4}, {"Min", 1}}},
+		{mbeanType: "Table", keyPath: "c", tags: []tag{{"keyspace", "app"}, {"cf", "users"}}, fields: []field{{"Count", 5}}},
+	}
+	got := mergeDuplicates(metrics, Config{})
+	if len(got) != 2 {
+		t.Fatalf("mergeDuplicates() = %+v, want 2 metrics", got)
+	}
+	want := []field{{"Count", 3}, {"Max", 9}, {"Min", 1}}
+	if !reflect.DeepEqual(got[0].fields, want) {
+		t.Errorf("merged fields = %v, want %v, the first metric winning", got[0].fields, want)
+	}
+	if got[1].keyPath != "c" {
+		t.Errorf("second metric = %+v, want the other MBean type kept apart", got[1])
+	}
+}
@@ -0,0 +1,51 @@
+package checker
+
+import "strings"
+
+// NetworkError is returned when the jolokia agent could not be reached or
+// the connection failed while reading the response.
+type NetworkError struct {
+	Err error
+}
+
+func (e *NetworkError) Error() string { return e.Err.Error() }
+func (e *NetworkError) Unwrap() error { return e.Err }
+
+// StatusError is returned when the jolokia agent answers with a non-200
+// HTTP status. ErrorType and Message hold the error of the body, when it
+// is a jolokia error, such as one from a proxy in front of the agents.
+type StatusError struct {
+	URL        string
+	StatusCode int
+	Status     string
+	ErrorType  string
+	Message    string
+}
+
+func (e *StatusError) Error() string {
+	if e.Message == "" && e.ErrorType == "" {
+		return e.URL + " " + e.Status
+	}
+	return e.URL + " " + e.Status + ": " + jolokiaMessage(e.ErrorType, e.Message)
+}
+
+// jolokiaMessage joins the error_type and error of a jolokia response,
+// leaving out the type when the error already starts with it.
+func jolokiaMessage(errorType, message string) string {
+	if errorType == "" || strings.HasPrefix(message, errorType) {
+		return message
+	}
+	if message == "" {
+		return errorType
+	}
+	return errorType + ": " + message
+}
+
+// ResponseError is returned when the response body can't be decoded or
+// carries an error reported by jolokia itself.
+type ResponseError struct {
+	Err error
+}
+
+func (e *ResponseError) Error() string { return e.Err.Error() }
+func (e *ResponseError) Unwrap() error { return e.Err }
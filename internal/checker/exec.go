@@ -0,0 +1,49 @@
+package checker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// execRequest invokes an MBean operation through jolokia.
+type execRequest struct {
+	Type      string   `json:"type"`
+	MBean     string   `json:"mbean"`
+	Operation string   `json:"operation"`
+	Arguments []string `json:"arguments"`
+}
+
+// Exec invokes operation on mbean through the jolokia agent at baseURL,
+// such as forceKeyspaceFlush, and returns its result. It is never part of
+// a scrape.
+func Exec(ctx context.Context, client *http.Client, baseURL *url.URL, cfg Config, mbean, operation string, args []string) (interface{}, error) {
+	if cfg.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.Timeout)
+		defer cancel()
+	}
+
+	if args == nil {
+		args = []string{}
+	}
+	body, err := json.Marshal(execRequest{Type: "exec", MBean: mbean, Operation: operation, Arguments: args})
+	if err != nil {
+		return nil, err
+	}
+
+	jsonResp := struct {
+		Status int         `json:"status"`
+		Error  string      `json:"error"`
+		Value  interface{} `json:"value"`
+	}{}
+	if err := doRequest(ctx, client, http.MethodPost, baseURL, body, cfg, &jsonResp); err != nil {
+		return nil, err
+	}
+	if jsonResp.Status != 200 || jsonResp.Error != "" {
+		return nil, &ResponseError{fmt.Errorf("jolokia status %d: %s", jsonResp.Status, jsonResp.Error)}
+	}
+	return jsonResp.Value, nil
+}
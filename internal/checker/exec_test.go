@@ -0,0 +1,55 @@
+package checker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestExec(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		response string
+		want     interface{}
+		wantArgs []string
+		wantErr  bool
+	}{
+		{"no result", nil, `{"status": 200, "value": null}`, nil, []string{}, false},
+		{"result", []string{"app"}, `{"status": 200, "value": {"flushed": true}}`, map[string]interface{}{"flushed": true}, []string{"app"}, false},
+		{"jolokia error", []string{"app", "users"}, `{"status": 404, "error": "No operation forceKeyspaceFlush found"}`, nil, []string{"app", "users"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var posted execRequest
+			baseURL := stubAgent(t, func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != http.MethodPost {
+					t.Errorf("method = %s, want POST", r.Method)
+				}
+				if err := json.NewDecoder(r.Body).Decode(&posted); err != nil {
+					t.Errorf("decoding the exec request: %v", err)
+				}
+				respondWith(tt.response)(w, r)
+			})
+			got, err := Exec(context.Background(), http.DefaultClient, baseURL, Config{},
+				"org.apache.cassandra.db:type=StorageService", "forceKeyspaceFlush", tt.args)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Exec() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			var respErr *ResponseError
+			if err != nil && !errors.As(err, &respErr) {
+				t.Errorf("Exec() error = %T, want a *ResponseError", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Exec() = %#v, want %#v", got, tt.want)
+			}
+			want := execRequest{Type: "exec", MBean: "org.apache.cassandra.db:type=StorageService", Operation: "forceKeyspaceFlush", Arguments: tt.wantArgs}
+			if !reflect.DeepEqual(posted, want) {
+				t.Errorf("posted %+v, want %+v", posted, want)
+			}
+		})
+	}
+}
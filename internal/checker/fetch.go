@@ -0,0 +1,596 @@
+package checker
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultMBeanType is the type of the MBeans read when none is configured.
+const DefaultMBeanType = "ColumnFamily"
+
+// readMBean returns the pattern matching every MBean of the given type.
+func readMBean(mbeanType string) string {
+	if mbeanType == DefaultMBeanType {
+		return "org.apache.cassandra.metrics:type=ColumnFamily,keyspace=*,scope=*,name=*"
+	}
+	return "org.apache.cassandra.metrics:type=" + mbeanType + ",*"
+}
+
+// readURL returns the URL reading every MBean of the given type from the
+// agent at baseURL, whatever trailing slashes it has, keeping its query and
+// adding the processing parameters.
+func readURL(baseURL *url.URL, mbeanType string, cfg Config) *url.URL {
+	loc := baseURL.JoinPath("read", readMBean(mbeanType))
+	if params := processingParams(cfg); len(params) > 0 {
+		query := loc.Query()
+		for key, value := range params {
+			query.Set(key, strconv.Itoa(value))
+		}
+		loc.RawQuery = query.Encode()
+	}
+	return loc
+}
+
+// processingParams returns the jolokia processing parameters limiting what
+// a read returns, leaving out the unset ones.
+func processingParams(cfg Config) map[string]int {
+	params := map[string]int{}
+	if cfg.MaxDepth > 0 {
+		params["maxDepth"] = cfg.MaxDepth
+	}
+	if cfg.MaxCollectionSize > 0 {
+		params["maxCollectionSize"] = cfg.MaxCollectionSize
+	}
+	if cfg.MaxObjects > 0 {
+		params["maxObjects"] = cfg.MaxObjects
+	}
+	return params
+}
+
+// mbeanTypes returns the configured MBean types, or the default one.
+func mbeanTypes(cfg Config) []string {
+	if len(cfg.MBeanTypes) == 0 {
+		return []string{DefaultMBeanType}
+	}
+	return cfg.MBeanTypes
+}
+
+// onlyDefaultMBeanType tells whether just the default type is read, in which
+// case the output is not tagged by type.
+func onlyDefaultMBeanType(cfg Config) bool {
+	types := mbeanTypes(cfg)
+	return len(types) == 1 && types[0] == DefaultMBeanType
+}
+
+// Response is the body of a jolokia read request.
+type Response struct {
+	Request struct {
+		MBean string `json:"mbean"`
+		Type  string `json:"type"`
+	} `json:"request"`
+	Status     int                               `json:"status"`
+	Error      string                            `json:"error"`
+	ErrorType  string                            `json:"error_type"`
+	StackTrace string                            `json:"stacktrace"`
+	TimeStamp  int64                             `json:"timestamp"`
+	Value      map[string]map[string]interface{} `json:"value"`
+
+	// Node identifies the agent the response came from, when several are
+	// scraped.
+	Node string `json:"-"`
+	// Tags are added to every series of the response, such as the
+	// discovered cluster tags.
+	Tags map[string]string `json:"-"`
+	// RequestDuration is how long the HTTP requests took, up to reading
+	// their body.
+	RequestDuration time.Duration `json:"-"`
+}
+
+// UnmarshalJSON decodes a jolokia response, taking a value that is not an
+// object of MBeans, such as null or a scalar, as no metrics rather than as a
+// malformed response. Reads of an exact MBean name get its attributes as
+// value, which are keyed by the MBean like the pattern reads.
+func (r *Response) UnmarshalJSON(data []byte) error {
+	type plain Response
+	aux := struct {
+		*plain
+		Value json.RawMessage `json:"value"`
+	}{plain: (*plain)(r)}
+	if err := decodeJSON(data, &aux); err != nil {
+		return err
+	}
+
+	r.Value = map[string]map[string]interface{}{}
+	value := bytes.TrimSpace(aux.Value)
+	if len(value) == 0 || value[0] != '{' {
+		if len(value) > 0 && string(value) != "null" {
+			slog.Debug("Ignoring jolokia value that is not an object", "mbean", r.Request.MBean, "value", string(value))
+		}
+		return nil
+	}
+	if r.Request.MBean != "" && !isPattern(r.Request.MBean) {
+		attributes := map[string]interface{}{}
+		if err := decodeJSON(value, &attributes); err != nil {
+			return err
+		}
+		r.Value[r.Request.MBean] = attributes
+		return nil
+	}
+	return decodeJSON(value, &r.Value)
+}
+
+// isPattern tells whether mbean is a pattern, which jolokia answers with the
+// attributes of every matching MBean keyed by their name.
+func isPattern(mbean string) bool {
+	return strings.ContainsAny(mbean, "*?")
+}
+
+// decodeJSON decodes data into v, keeping numbers as json.Number.
+func decodeJSON(data []byte, v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return decoder.Decode(v)
+}
+
+// NewClient builds the HTTP client used to talk to the jolokia agent.
+func NewClient(cfg Config) (*http.Client, error) {
+	tlsConfig, err := newTLSConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	// Idle connections are kept across scrapes, so daemon mode does not pay
+	// a TLS handshake on every tick. The limit is per agent, MaxIdleConns
+	// being left unlimited so agents do not evict each other's connections.
+	tr := &http.Transport{
+		Proxy:               http.ProxyFromEnvironment,
+		DialContext:         (&net.Dialer{Timeout: cfg.DialTimeout}).DialContext,
+		TLSClientConfig:     tlsConfig,
+		MaxIdleConnsPerHost: cfg.MaxIdleConnsPerHost,
+		IdleConnTimeout:     cfg.IdleConnTimeout,
+	}
+	if cfg.Proxy != nil {
+		tr.Proxy = http.ProxyURL(cfg.Proxy)
+	}
+	return &http.Client{Transport: tr, Timeout: cfg.Timeout}, nil
+}
+
+func newTLSConfig(cfg Config) (*tls.Config, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: cfg.InsecureSkipVerify}
+
+	if cfg.CACert != "" {
+		pem, err := os.ReadFile(cfg.CACert)
+		if err != nil {
+			return nil, fmt.Errorf("could not read CA certificate: %s", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", cfg.CACert)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if cfg.Cert != "" || cfg.Key != "" {
+		if cfg.Cert == "" || cfg.Key == "" {
+			return nil, errors.New("--cert and --key must be set together")
+		}
+		cert, err := tls.LoadX509KeyPair(cfg.Cert, cfg.Key)
+		if err != nil {
+			return nil, fmt.Errorf("could not load client certificate: %s", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
+
+// retryableError marks a failed fetch that is worth attempting again.
+type retryableError struct {
+	err error
+}
+
+func (e retryableError) Error() string { return e.err.Error() }
+func (e retryableError) Unwrap() error { return e.err }
+
+// Result is the outcome of fetching from one jolokia agent.
+type Result struct {
+	URL      *url.URL
+	Node     string
+	Tags     map[string]string
+	Response *Response
+	Err      error
+	Duration time.Duration
+}
+
+// FetchAll fetches from every configured jolokia agent, at most
+// cfg.Concurrency at a time. When there are several agents, the results
+// carry the host:port of their agent as node.
+func FetchAll(ctx context.Context, client *http.Client, cfg Config) []Result {
+	if RequestID(ctx) == "" {
+		ctx = WithRequestID(ctx, newRequestID(cfg))
+	}
+	results := make([]Result, len(cfg.JolokiaURLs))
+	workers := cfg.Concurrency
+	if workers < 1 {
+		workers = 1
+	}
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				baseURL := cfg.JolokiaURLs[i]
+				result := Result{URL: baseURL}
+				if len(cfg.JolokiaURLs) > 1 {
+					result.Node = nodeName(baseURL)
+				}
+				result.Tags = cfg.NodeTags[baseURL.String()]
+				start := time.Now()
+				result.Response, result.Err = Fetch(ctx, client, baseURL, cfg)
+				result.Duration = time.Since(start)
+				if result.Response != nil {
+					result.Response.Node = result.Node
+					result.Response.Tags = result.Tags
+				}
+				results[i] = result
+			}
+		}()
+	}
+	for i := range cfg.JolokiaURLs {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	return results
+}
+
+// nodeName identifies the agent at baseURL as host:port, keeping IPv6
+// addresses bracketed, or as the bare host when the URL has no port.
+func nodeName(baseURL *url.URL) string {
+	if baseURL.Port() == "" {
+		return baseURL.Hostname()
+	}
+	return net.JoinHostPort(baseURL.Hostname(), baseURL.Port())
+}
+
+// Fetch reads the metrics of every table from the jolokia agent at baseURL,
+// retrying as configured.
+func Fetch(ctx context.Context, client *http.Client, baseURL *url.URL, cfg Config) (*Response, error) {
+	fetch := fetchRead
+	if bulk(cfg) {
+		fetch = fetchBulk
+	}
+
+	attempt := func() (*Response, error) {
+		if cfg.Timeout > 0 {
+			ctx, cancel := context.WithTimeout(ctx, cfg.Timeout)
+			defer cancel()
+			return fetch(ctx, client, baseURL, cfg)
+		}
+		return fetch(ctx, client, baseURL, cfg)
+	}
+
+	delay := cfg.RetryDelay
+	for n := 1; ; n++ {
+		resp, err := attempt()
+		if err == nil {
+			return resp, nil
+		}
+		var rerr retryableError
+		if n > cfg.Retries || !errors.As(err, &rerr) || ctx.Err() != nil {
+			return nil, requestError(err, cfg)
+		}
+		slog.Debug("Retrying jolokia request", "attempt", n, "attempts", cfg.Retries+1, "delay", delay, "error", err)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+// fetchRead reads every table metric with a single GET request.
+func fetchRead(ctx context.Context, client *http.Client, baseURL *url.URL, cfg Config) (*Response, error) {
+	merged := &Response{Status: 200, Value: map[string]map[string]interface{}{}}
+	for _, mbeanType := range mbeanTypes(cfg) {
+		loc := readURL(baseURL, mbeanType, cfg)
+		jsonResp := &Response{}
+		start := time.Now()
+		if err := doRequest(ctx, client, http.MethodGet, loc, nil, cfg, jsonResp); err != nil {
+			return nil, err
+		}
+		jsonResp.RequestDuration = time.Since(start)
+		if err := checkStatus(jsonResp); err != nil {
+			return nil, err
+		}
+		if err := checkEmpty(jsonResp, readMBean(mbeanType), cfg); err != nil {
+			return nil, err
+		}
+		merged.merge(jsonResp)
+	}
+	return merged, nil
+}
+
+// merge adds the values read in other to r.
+func (r *Response) merge(other *Response) {
+	r.TimeStamp = other.TimeStamp
+	r.RequestDuration += other.RequestDuration
+	for keyPath, valueMap := range other.Value {
+		r.Value[keyPath] = valueMap
+	}
+}
+
+// bulkRequest is a single read in a jolokia bulk request.
+type bulkRequest struct {
+	Type   string         `json:"type"`
+	MBean  string         `json:"mbean"`
+	Config map[string]int `json:"config,omitempty"`
+	Target *proxyTarget   `json:"target,omitempty"`
+}
+
+// proxyTarget is the remote JMX agent a jolokia proxy reads from.
+type proxyTarget struct {
+	URL      string `json:"url"`
+	User     string `json:"user,omitempty"`
+	Password string `json:"password,omitempty"`
+}
+
+// newProxyTarget returns the target of the requests, or nil when jolokia is
+// not used as a proxy.
+func newProxyTarget(cfg Config) *proxyTarget {
+	if cfg.TargetURL == "" {
+		return nil
+	}
+	return &proxyTarget{URL: cfg.TargetURL, User: cfg.TargetUser, Password: cfg.TargetPassword}
+}
+
+// bulk reports whether the metrics are read with fetchBulk. Reads through a
+// jolokia proxy carry their target in a POSTed body, so they always are.
+func bulk(cfg Config) bool {
+	return cfg.Bulk || cfg.TargetURL != ""
+}
+
+// fetchBulk POSTs one read per MBean pattern built from the filters, so
+// the agent only reads what would be emitted, and merges the responses.
+// Reads that failed are logged and left out, unless all of them failed.
+func fetchBulk(ctx context.Context, client *http.Client, baseURL *url.URL, cfg Config) (*Response, error) {
+	requests := []bulkRequest{}
+	for _, pattern := range bulkPatterns(cfg) {
+		requests = append(requests, bulkRequest{Type: "read", MBean: pattern, Config: processingParams(cfg), Target: newProxyTarget(cfg)})
+	}
+	body, err := json.Marshal(requests)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := []Response{}
+	start := time.Now()
+	if err := doRequest(ctx, client, http.MethodPost, baseURL, body, cfg, &responses); err != nil {
+		return nil, err
+	}
+
+	merged := &Response{Status: 200, Value: map[string]map[string]interface{}{}, RequestDuration: time.Since(start)}
+	errs := []error{}
+	for i := range responses {
+		if err := checkStatus(&responses[i]); err != nil {
+			slog.Warn("Jolokia bulk read failed", "mbean", responses[i].Request.MBean, "error", responses[i].Error)
+			errs = append(errs, err)
+			continue
+		}
+		if err := checkEmpty(&responses[i], responses[i].Request.MBean, cfg); err != nil {
+			return nil, err
+		}
+		merged.merge(&responses[i])
+	}
+	if len(errs) > 0 && len(errs) == len(responses) {
+		return nil, errors.Join(errs...)
+	}
+	return merged, nil
+}
+
+// bulkPatterns returns the MBean patterns matching the included metrics,
+// keyspaces and tables. Keyspace and table filters only narrow the
+// ColumnFamily reads, as other types are not organised by table.
+func bulkPatterns(cfg Config) []string {
+	orWildcard := func(list []string) []string {
+		if len(list) == 0 {
+			return []string{"*"}
+		}
+		return list
+	}
+
+	patterns := []string{}
+	for _, mbeanType := range mbeanTypes(cfg) {
+		if mbeanType != DefaultMBeanType {
+			for _, name := range orWildcard(cfg.Include) {
+				patterns = append(patterns, fmt.Sprintf(
+					"org.apache.cassandra.metrics:type=%s,name=%s,*", mbeanType, name))
+			}
+			continue
+		}
+		for _, keyspace := range orWildcard(cfg.Keyspaces) {
+			for _, table := range orWildcard(cfg.Tables) {
+				for _, name := range orWildcard(cfg.Include) {
+					patterns = append(patterns, fmt.Sprintf(
+						"org.apache.cassandra.metrics:type=ColumnFamily,keyspace=%s,scope=%s,name=%s",
+						keyspace, table, name))
+				}
+			}
+		}
+	}
+	return patterns
+}
+
+// doRequest sends a request to the jolokia agent and decodes the JSON
+// response into v.
+func doRequest(ctx context.Context, client *http.Client, method string, loc *url.URL, body []byte, cfg Config, v interface{}) error {
+	var reqBody io.Reader
+	if body != nil {
+		reqBody = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, loc.String(), reqBody)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if cfg.User != "" && cfg.Password != "" {
+		req.SetBasicAuth(cfg.User, cfg.Password)
+	}
+	// Asking for gzip explicitly turns off the transparent decompression of
+	// the transport, so the body is decompressed below.
+	req.Header.Set("Accept-Encoding", "gzip")
+	if id := RequestID(ctx); id != "" {
+		req.Header.Set(requestIDHeader, id)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return retryableError{&NetworkError{err}}
+	}
+	defer func() {
+		// Draining what the decoder left unread lets the connection be reused.
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+		resp.Body.Close()
+	}()
+	if resp.StatusCode != 200 {
+		err := &StatusError{URL: loc.String(), StatusCode: resp.StatusCode, Status: resp.Status}
+		err.ErrorType, err.Message = decodeError(resp)
+		if resp.StatusCode >= 500 {
+			return retryableError{err}
+		}
+		return err
+	}
+
+	var respBody io.Reader = resp.Body
+	if resp.Header.Get("Content-Encoding") == "gzip" {
+		gz, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			return &ResponseError{err}
+		}
+		defer gz.Close()
+		respBody = gz
+	}
+	if cfg.MaxResponseBytes > 0 {
+		respBody = &maxBytesReader{r: respBody, limit: cfg.MaxResponseBytes}
+	}
+
+	decoder := json.NewDecoder(respBody)
+	decoder.UseNumber()
+	if err := decoder.Decode(v); err != nil {
+		var nerr net.Error
+		if errors.As(err, &nerr) {
+			return &NetworkError{err}
+		}
+		var terr *json.UnmarshalTypeError
+		if errors.As(err, &terr) {
+			return &ResponseError{fmt.Errorf("unexpected %s for %s in the jolokia response", terr.Value, terr.Field)}
+		}
+		return &ResponseError{err}
+	}
+	return nil
+}
+
+// maxErrorBytes bounds the body read from a non-200 HTTP response.
+const maxErrorBytes = 64 << 10
+
+// decodeError returns the error_type and error of a jolokia error in the
+// body of a non-200 HTTP response, or empty strings when the body is not one.
+func decodeError(resp *http.Response) (string, string) {
+	var body io.Reader = io.LimitReader(resp.Body, maxErrorBytes)
+	if resp.Header.Get("Content-Encoding") == "gzip" {
+		gz, err := gzip.NewReader(body)
+		if err != nil {
+			return "", ""
+		}
+		defer gz.Close()
+		body = gz
+	}
+	jsonResp := struct {
+		Error     string `json:"error"`
+		ErrorType string `json:"error_type"`
+	}{}
+	if err := json.NewDecoder(body).Decode(&jsonResp); err != nil {
+		return "", ""
+	}
+	return jsonResp.ErrorType, jsonResp.Error
+}
+
+// maxBytesReader fails once more than limit bytes were read, so a runaway
+// response is not decoded into memory.
+type maxBytesReader struct {
+	r     io.Reader
+	read  int64
+	limit int64
+}
+
+func (m *maxBytesReader) Read(p []byte) (int, error) {
+	n, err := m.r.Read(p)
+	m.read += int64(n)
+	if m.read > m.limit {
+		return 0, fmt.Errorf("jolokia response larger than %d bytes", m.limit)
+	}
+	return n, err
+}
+
+// checkStatus reports the error carried in the body of a jolokia response.
+func checkStatus(jsonResp *Response) error {
+	if jsonResp.Status != 200 || jsonResp.Error != "" {
+		err := &ResponseError{fmt.Errorf("jolokia status %d: %s", jsonResp.Status, jolokiaMessage(jsonResp.ErrorType, jsonResp.Error))}
+		if jsonResp.Status == 503 {
+			return retryableError{err}
+		}
+		return err
+	}
+	return nil
+}
+
+// checkEmpty warns when a read matched no MBean, which otherwise silently
+// produces no output, and fails when --fail-on-empty is set.
+func checkEmpty(jsonResp *Response, mbean string, cfg Config) error {
+	if len(jsonResp.Value) > 0 {
+		return nil
+	}
+	if cfg.FailOnEmpty {
+		return &ResponseError{fmt.Errorf("jolokia returned 0 metrics for %s", mbean)}
+	}
+	slog.Warn("Jolokia returned 0 metrics", "mbean", mbean)
+	return nil
+}
+
+// requestError replaces timeouts with a message stating the configured
+// timeout that fired, the dial one when connecting took too long.
+func requestError(err error, cfg Config) error {
+	var nerr net.Error
+	if !errors.As(err, &nerr) || !nerr.Timeout() {
+		return err
+	}
+	var operr *net.OpError
+	if errors.As(err, &operr) && operr.Op == "dial" && cfg.DialTimeout > 0 &&
+		(cfg.Timeout == 0 || cfg.DialTimeout < cfg.Timeout) {
+		return &NetworkError{fmt.Errorf("jolokia connection timed out after %s", cfg.DialTimeout)}
+	}
+	return &NetworkError{fmt.Errorf("jolokia request timed out after %s", cfg.Timeout)}
+}
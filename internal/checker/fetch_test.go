@@ -0,0 +1,555 @@
+package checker
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestRequestError(t *testing.T) {
+	cfg := Config{Timeout: 10 * time.Second, DialTimeout: 5 * time.Second}
+	dial := &url.Error{Op: "Get", URL: "http://cassandra:8778/jolokia", Err: &net.OpError{Op: "dial", Net: "tcp", Err: timeoutError{}}}
+	read := &url.Error{Op: "Get", URL: "http://cassandra:8778/jolokia", Err: &net.OpError{Op: "read", Net: "tcp", Err: timeoutError{}}}
+	refused := errors.New("connection refused")
+
+	tests := []struct {
+		name string
+		err  error
+		cfg  Config
+		want string
+	}{
+		{"dial timeout", dial, cfg, "jolokia connection timed out after 5s"},
+		{"read timeout", read, cfg, "jolokia request timed out after 10s"},
+		{"dial timeout above the request one", dial, Config{Timeout: 2 * time.Second, DialTimeout: 5 * time.Second}, "jolokia request timed out after 2s"},
+		{"dial timeout without request timeout", dial, Config{DialTimeout: 5 * time.Second}, "jolokia connection timed out after 5s"},
+		{"not a timeout", refused, cfg, "connection refused"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := requestError(tt.err, tt.cfg).Error(); got != tt.want {
+				t.Errorf("requestError() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// flakyAgent fails the first failures requests with fail, answering the
+// following ones with a metric, and counts the requests it got.
+func flakyAgent(t *testing.T, failures int, fail http.HandlerFunc) (*url.URL, *atomic.Int32) {
+	t.Helper()
+	attempts := &atomic.Int32{}
+	baseURL := stubAgent(t, func(w http.ResponseWriter, r *http.Request) {
+		if int(attempts.Add(1)) <= failures {
+			fail(w, r)
+			return
+		}
+		io.WriteString(w, `{"status": 200, "timestamp": 1, "value": {"a": {"Count": 1}}}`)
+	})
+	return baseURL, attempts
+}
+
+// dropConnection closes the connection without answering.
+func dropConnection(w http.ResponseWriter, r *http.Request) {
+	conn, _, err := w.(http.Hijacker).Hijack()
+	if err == nil {
+		conn.Close()
+	}
+}
+
+// respondStatus answers with the HTTP status and body.
+func respondStatus(status int, body string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		io.WriteString(w, body)
+	}
+}
+
+func TestFetchRetries(t *testing.T) {
+	tests := []struct {
+		name         string
+		failures     int
+		fail         http.HandlerFunc
+		wantAttempts int32
+		wantErr      bool
+	}{
+		{"connection error", 10, dropConnection, 3, true},
+		{"http 500", 10, respondStatus(500, "internal error"), 3, true},
+		{"http 502 then success", 1, respondStatus(502, "bad gateway"), 2, false},
+		{"json status 503", 10, respondWith(`{"status": 503, "error": "unavailable"}`), 3, true},
+		{"http 404", 10, respondStatus(404, "not found"), 1, true},
+		{"json status 404", 10, respondWith(`{"status": 404, "error": "not found"}`), 1, true},
+		{"decode error", 10, respondWith(`{"status": 200, "value": `), 1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			baseURL, attempts := flakyAgent(t, tt.failures, tt.fail)
+			client := &http.Client{Transport: &http.Transport{}}
+			_, err := Fetch(context.Background(), client, baseURL, Config{Retries: 2, RetryDelay: time.Millisecond})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Fetch() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := attempts.Load(); got != tt.wantAttempts {
+				t.Errorf("Fetch() made %d attempts, want %d", got, tt.wantAttempts)
+			}
+		})
+	}
+}
+
+func TestFetchRetryCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	baseURL, attempts := flakyAgent(t, 10, respondStatus(500, "internal error"))
+	time.AfterFunc(50*time.Millisecond, cancel)
+	start := time.Now()
+	_, err := Fetch(ctx, http.DefaultClient, baseURL, Config{Retries: 3, RetryDelay: time.Hour})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Fetch() error = %v, want context.Canceled", err)
+	}
+	if got := attempts.Load(); got != 1 {
+		t.Errorf("Fetch() made %d attempts, want 1", got)
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("Fetch() returned after %s, want the backoff cut short", elapsed)
+	}
+}
+
+func TestNewClientIdleConns(t *testing.T) {
+	client, err := NewClient(Config{MaxIdleConnsPerHost: 4, IdleConnTimeout: 5 * time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := client.Transport.(*http.Transport)
+	if tr.MaxIdleConns != 0 {
+		t.Errorf("MaxIdleConns = %d, want 0 so agents do not evict each other's connections", tr.MaxIdleConns)
+	}
+	if tr.MaxIdleConnsPerHost != 4 {
+		t.Errorf("MaxIdleConnsPerHost = %d, want 4", tr.MaxIdleConnsPerHost)
+	}
+	if tr.IdleConnTimeout != 5*time.Minute {
+		t.Errorf("IdleConnTimeout = %s, want 5m", tr.IdleConnTimeout)
+	}
+}
+
+func TestFetchReusesConnections(t *testing.T) {
+	body := `{"status": 200, "timestamp": 1, "value": {"org.apache.cassandra.metrics:keyspace=app,name=ReadLatency,scope=users,type=ColumnFamily": {"Count": 3}}}`
+	tests := []struct {
+		name string
+		tls  bool
+	}{
+		{"http", false},
+		{"tls", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var conns atomic.Int32
+			server := httptest.NewUnstartedServer(respondWith(body))
+			server.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+				if state == http.StateNew {
+					conns.Add(1)
+				}
+			}
+			if tt.tls {
+				server.StartTLS()
+			} else {
+				server.Start()
+			}
+			defer server.Close()
+
+			baseURL, err := url.Parse(server.URL + "/jolokia")
+			if err != nil {
+				t.Fatal(err)
+			}
+			cfg := Config{InsecureSkipVerify: true, MaxIdleConnsPerHost: 2, IdleConnTimeout: time.Minute}
+			client, err := NewClient(cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := 0; i < 2; i++ {
+				if _, err := Fetch(context.Background(), client, baseURL, cfg); err != nil {
+					t.Fatalf("Fetch() error = %v", err)
+				}
+			}
+			if got := conns.Load(); got != 1 {
+				t.Errorf("opened %d connections, want 1 reused across the requests", got)
+			}
+		})
+	}
+}
+
+// stubAgent runs handler as a jolokia agent, returning its base URL.
+func stubAgent(t *testing.T, handler http.HandlerFunc) *url.URL {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	u, err := url.Parse(server.URL + "/jolokia")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+// respondWith answers every request with body.
+func respondWith(body string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, body)
+	}
+}
+
+func TestFetchNonObjectValue(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"scalar", `{"status": 200, "timestamp": 1, "value": 5}`},
+		{"string", `{"status": 200, "timestamp": 1, "value": "none"}`},
+		{"array", `{"status": 200, "timestamp": 1, "value": [1, 2]}`},
+		{"null", `{"status": 200, "timestamp": 1, "value": null}`},
+		{"missing", `{"status": 200, "timestamp": 1}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			baseURL := stubAgent(t, respondWith(tt.body))
+			resp, err := Fetch(context.Background(), http.DefaultClient, baseURL, Config{})
+			if err != nil {
+				t.Fatalf("Fetch() error = %v, want none", err)
+			}
+			if len(resp.Value) != 0 {
+				t.Errorf("Fetch() value = %v, want no metrics", resp.Value)
+			}
+
+			_, err = Fetch(context.Background(), http.DefaultClient, baseURL, Config{FailOnEmpty: true})
+			var rerr *ResponseError
+			if !errors.As(err, &rerr) {
+				t.Errorf("Fetch() with FailOnEmpty error = %v, want a ResponseError", err)
+			}
+		})
+	}
+}
+
+// bulkAgent answers every read of a bulk request with value, echoing the
+// read as jolokia does. Reads whose MBean is in missing get a 404.
+func bulkAgent(t *testing.T, value string, missing ...string) (*url.URL, *[]bulkRequest) {
+	t.Helper()
+	posted := &[]bulkRequest{}
+	baseURL := stubAgent(t, func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(posted); err != nil {
+			t.Errorf("decoding the bulk request: %v", err)
+		}
+		responses := []string{}
+		for _, req := range *posted {
+			request := fmt.Sprintf(`{"type": "read", "mbean": %q}`, req.MBean)
+			if contains(missing, req.MBean) {
+				responses = append(responses, fmt.Sprintf(`{"request": %s, "status": 404, "error": "not found"}`, request))
+				continue
+			}
+			responses = append(responses, fmt.Sprintf(`{"request": %s, "status": 200, "timestamp": 1, "value": %s}`, request, value))
+		}
+		io.WriteString(w, "["+strings.Join(responses, ",")+"]")
+	})
+	return baseURL, posted
+}
+
+func TestFetchBulkExactMBean(t *testing.T) {
+	baseURL, _ := bulkAgent(t, `{"Count": 3, "Mean": 1.5}`)
+	cfg := Config{Bulk: true, Include: []string{"ReadLatency"}, Keyspaces: []string{"ks"}, Tables: []string{"t"}}
+	resp, err := Fetch(context.Background(), http.DefaultClient, baseURL, cfg)
+	if err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+	mbean := "org.apache.cassandra.metrics:type=ColumnFamily,keyspace=ks,scope=t,name=ReadLatency"
+	attributes, ok := resp.Value[mbean]
+	if !ok || len(resp.Value) != 1 {
+		t.Fatalf("Fetch() value = %v, want the attributes of %s", resp.Value, mbean)
+	}
+	if attributes["Count"] != json.Number("3") {
+		t.Errorf("Count = %v, want 3", attributes["Count"])
+	}
+}
+
+func TestIsPattern(t *testing.T) {
+	tests := []struct {
+		mbean string
+		want  bool
+	}{
+		{"org.apache.cassandra.metrics:type=ColumnFamily,keyspace=*,scope=*,name=*", true},
+		{"org.apache.cassandra.metrics:type=Cache,*", true},
+		{"org.apache.cassandra.metrics:type=ColumnFamily,keyspace=ks?,scope=t,name=ReadLatency", true},
+		{"org.apache.cassandra.metrics:type=ColumnFamily,keyspace=ks,scope=t,name=ReadLatency", false},
+	}
+	for _, tt := range tests {
+		if got := isPattern(tt.mbean); got != tt.want {
+			t.Errorf("isPattern(%q) = %v, want %v", tt.mbean, got, tt.want)
+		}
+	}
+}
+
+func TestFetchMBeanTypes(t *testing.T) {
+	paths := []string{}
+	baseURL := stubAgent(t, func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		io.WriteString(w, `{"status": 200, "timestamp": 1, "value": {"org.apache.cassandra.metrics:name=ActiveTasks,path=request,scope=ReadStage,type=ThreadPools": {"Value": 2}}}`)
+	})
+	cfg := Config{MBeanTypes: []string{"ColumnFamily", "ThreadPools"}}
+	if _, err := Fetch(context.Background(), http.DefaultClient, baseURL, cfg); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"/jolokia/read/org.apache.cassandra.metrics:type=ColumnFamily,keyspace=*,scope=*,name=*",
+		"/jolokia/read/org.apache.cassandra.metrics:type=ThreadPools,*",
+	}
+	if strings.Join(paths, " ") != strings.Join(want, " ") {
+		t.Errorf("requested %q, want %q", paths, want)
+	}
+}
+
+func TestFetchThroughProxy(t *testing.T) {
+	proxied := []string{}
+	proxy := stubAgent(t, func(w http.ResponseWriter, r *http.Request) {
+		proxied = append(proxied, r.URL.Host)
+		io.WriteString(w, `{"status": 200, "timestamp": 1, "value": {"org.apache.cassandra.metrics:keyspace=app,name=ReadLatency,scope=users,type=ColumnFamily": {"Count": 3}}}`)
+	})
+	proxy.Path = ""
+	client, err := NewClient(Config{Proxy: proxy})
+	if err != nil {
+		t.Fatal(err)
+	}
+	baseURL, _ := url.Parse("http://cassandra.invalid:8778/jolokia")
+	resp, err := Fetch(context.Background(), client, baseURL, Config{})
+	if err != nil {
+		t.Fatalf("Fetch() error = %v", err)
+	}
+	if len(resp.Value) != 1 {
+		t.Errorf("Fetch() value = %v, want the metric of the proxy", resp.Value)
+	}
+	if len(proxied) != 1 || proxied[0] != "cassandra.invalid:8778" {
+		t.Errorf("proxied requests to %q, want one to cassandra.invalid:8778", proxied)
+	}
+}
+
+func TestFetchProxyTarget(t *testing.T) {
+	const jmx = "service:jmx:rmi:///jndi/rmi://cassandra-1:7199/jmxrmi"
+	tests := []struct {
+		name string
+		cfg  Config
+		want *proxyTarget
+	}{
+		{"with credentials", Config{TargetURL: jmx, TargetUser: "monitor", TargetPassword: "secret"},
+			&proxyTarget{URL: jmx, User: "monitor", Password: "secret"}},
+		{"without credentials", Config{TargetURL: jmx}, &proxyTarget{URL: jmx}},
+		{"no target", Config{Bulk: true}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			baseURL, posted := bulkAgent(t, `{"Count": 3}`)
+			cfg := tt.cfg
+			cfg.Include, cfg.Keyspaces, cfg.Tables = []string{"ReadLatency"}, []string{"ks"}, []string{"t"}
+			if _, err := Fetch(context.Background(), http.DefaultClient, baseURL, cfg); err != nil {
+				t.Fatalf("Fetch() error = %v", err)
+			}
+			if len(*posted) != 1 {
+				t.Fatalf("posted %+v, want one read", *posted)
+			}
+			if got := (*posted)[0].Target; !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("posted target %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFetchBulkPartialErrors(t *testing.T) {
+	read := "org.apache.cassandra.metrics:type=ColumnFamily,keyspace=ks,scope=t,name=ReadLatency"
+	write := "org.apache.cassandra.metrics:type=ColumnFamily,keyspace=ks,scope=t,name=WriteLatency"
+	cfg := Config{Bulk: true, Include: []string{"ReadLatency", "WriteLatency"}, Keyspaces: []string{"ks"}, Tables: []string{"t"}}
+
+	baseURL, _ := bulkAgent(t, `{"Count": 3}`, write)
+	resp, err := Fetch(context.Background(), http.DefaultClient, baseURL, cfg)
+	if err != nil {
+		t.Fatalf("Fetch() error = %v, want none while a read succeeded", err)
+	}
+	if _, ok := resp.Value[read]; !ok || len(resp.Value) != 1 {
+		t.Errorf("Fetch() value = %v, want only %s", resp.Value, read)
+	}
+
+	baseURL, _ = bulkAgent(t, `{"Count": 3}`, read, write)
+	if _, err := Fetch(context.Background(), http.DefaultClient, baseURL, cfg); err == nil {
+		t.Error("Fetch() error = nil, want one when every read failed")
+	}
+}
+
+func TestFetchGzip(t *testing.T) {
+	body := `{"status": 200, "timestamp": 1, "value": {"org.apache.cassandra.metrics:keyspace=app,name=ReadLatency,scope=users,type=ColumnFamily": {"Count": 3}}}`
+	tests := []struct {
+		name string
+		gzip bool
+	}{
+		{"gzip", true},
+		{"identity", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			baseURL := stubAgent(t, func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("Accept-Encoding") != "gzip" {
+					t.Errorf("Accept-Encoding = %q, want gzip", r.Header.Get("Accept-Encoding"))
+				}
+				if !tt.gzip {
+					io.WriteString(w, body)
+					return
+				}
+				w.Header().Set("Content-Encoding", "gzip")
+				gz := gzip.NewWriter(w)
+				io.WriteString(gz, body)
+				gz.Close()
+			})
+			resp, err := Fetch(context.Background(), http.DefaultClient, baseURL, Config{})
+			if err != nil {
+				t.Fatalf("Fetch() error = %v", err)
+			}
+			if len(resp.Value) != 1 {
+				t.Errorf("Fetch() value = %v, want one metric", resp.Value)
+			}
+		})
+	}
+}
+
+func TestReadURL(t *testing.T) {
+	const read = "read/org.apache.cassandra.metrics:type=ColumnFamily,keyspace=*,scope=*,name=*"
+	tests := []struct {
+		name, base string
+		cfg        Config
+		want       string
+	}{
+		{"root", "http://cassandra:8778/jolokia", Config{}, "http://cassandra:8778/jolokia/" + read},
+		{"trailing slash", "http://cassandra:8778/jolokia/", Config{}, "http://cassandra:8778/jolokia/" + read},
+		{"trailing slashes", "http://cassandra:8778/jolokia//", Config{}, "http://cassandra:8778/jolokia/" + read},
+		{"context path", "https://host/monitoring/jolokia", Config{}, "https://host/monitoring/jolokia/" + read},
+		{"ipv6", "http://[::1]:8778/jolokia", Config{}, "http://[::1]:8778/jolokia/" + read},
+		{"ipv6 with a zone", "http://[fe80::1%25eth0]:8778/jolokia/", Config{}, "http://[fe80::1%25eth0]:8778/jolokia/" + read},
+		{"query", "https://host/monitoring/jolokia/?token=abc", Config{}, "https://host/monitoring/jolokia/" + read + "?token=abc"},
+		{"query and processing parameters", "https://host/jolokia?token=abc", Config{MaxDepth: 3}, "https://host/jolokia/" + read + "?maxDepth=3&token=abc"},
+		{"unset processing parameters", "http://cassandra:8778/jolokia", Config{MaxDepth: 0, MaxCollectionSize: 0, MaxObjects: 0}, "http://cassandra:8778/jolokia/" + read},
+		{"max depth", "http://cassandra:8778/jolokia", Config{MaxDepth: 2}, "http://cassandra:8778/jolokia/" + read + "?maxDepth=2"},
+		{"max collection size", "http://cassandra:8778/jolokia", Config{MaxCollectionSize: 100}, "http://cassandra:8778/jolokia/" + read + "?maxCollectionSize=100"},
+		{"max objects", "http://cassandra:8778/jolokia", Config{MaxObjects: 5000}, "http://cassandra:8778/jolokia/" + read + "?maxObjects=5000"},
+		{"all processing parameters", "http://cassandra:8778/jolokia", Config{MaxDepth: 2, MaxCollectionSize: 100, MaxObjects: 5000}, "http://cassandra:8778/jolokia/" + read + "?maxCollectionSize=100&maxDepth=2&maxObjects=5000"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			base, err := url.Parse(tt.base)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := readURL(base, DefaultMBeanType, tt.cfg).String(); got != tt.want {
+				t.Errorf("readURL() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFetchMaxResponseBytes(t *testing.T) {
+	body := `{"status": 200, "timestamp": 1, "value": {"org.apache.cassandra.metrics:keyspace=app,name=ReadLatency,scope=users,type=ColumnFamily": {"Count": 3}}}`
+	tests := []struct {
+		name    string
+		limit   int64
+		gzip    bool
+		wantErr bool
+	}{
+		{"no limit", 0, false, false},
+		{"below the limit", int64(len(body)), false, false},
+		{"above the limit", 64, false, true},
+		{"decompressed above the limit", 64, true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			baseURL := stubAgent(t, func(w http.ResponseWriter, r *http.Request) {
+				if !tt.gzip {
+					io.WriteString(w, body)
+					return
+				}
+				w.Header().Set("Content-Encoding", "gzip")
+				gz := gzip.NewWriter(w)
+				io.WriteString(gz, body)
+				gz.Close()
+			})
+			_, err := Fetch(context.Background(), http.DefaultClient, baseURL, Config{MaxResponseBytes: tt.limit})
+			if !tt.wantErr {
+				if err != nil {
+					t.Errorf("Fetch() error = %v, want none", err)
+				}
+				return
+			}
+			var rerr *ResponseError
+			if !errors.As(err, &rerr) || !strings.Contains(err.Error(), "jolokia response larger than 64 bytes") {
+				t.Errorf("Fetch() error = %v, want a ResponseError naming the limit", err)
+			}
+		})
+	}
+}
+
+func TestFetchStatusDisagreement(t *testing.T) {
+	tests := []struct {
+		name       string
+		httpStatus int
+		body       string
+		// wantStatus is the HTTP status of the StatusError, or 0 for a
+		// ResponseError.
+		wantStatus int
+		wantErr    string
+	}{
+		{"http 200 json 200", 200, `{"status": 200, "timestamp": 1, "value": {"a": {"Count": 1}}}`, 0, ""},
+		{"http 200 json 404", 200, `{"status": 404, "error_type": "javax.management.InstanceNotFoundException", "error": "javax.management.InstanceNotFoundException : no such mbean"}`,
+			0, "jolokia status 404: javax.management.InstanceNotFoundException : no such mbean"},
+		{"http 500 json 500", 500, `{"status": 500, "error_type": "java.io.IOException", "error": "connection to the node refused"}`,
+			500, "500 Internal Server Error: java.io.IOException: connection to the node refused"},
+		{"http 500 not json", 500, `<html>upstream failed</html>`, 500, "500 Internal Server Error"},
+		{"http 404 json 200", 404, `{"status": 200, "timestamp": 1, "value": {"a": {"Count": 1}}}`, 404, "404 Not Found"},
+		{"http 403 json error type only", 403, `{"status": 403, "error_type": "java.lang.SecurityException"}`,
+			403, "403 Forbidden: java.lang.SecurityException"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			baseURL := stubAgent(t, func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.httpStatus)
+				io.WriteString(w, tt.body)
+			})
+			_, err := Fetch(context.Background(), http.DefaultClient, baseURL, Config{})
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Fetch() error = %v, want none", err)
+				}
+				return
+			}
+			if err == nil || !strings.HasSuffix(err.Error(), tt.wantErr) {
+				t.Fatalf("Fetch() error = %v, want one ending with %q", err, tt.wantErr)
+			}
+			var serr *StatusError
+			var rerr *ResponseError
+			switch {
+			case tt.wantStatus == 0 && !errors.As(err, &rerr):
+				t.Errorf("Fetch() error = %T, want a ResponseError", err)
+			case tt.wantStatus != 0 && !errors.As(err, &serr):
+				t.Errorf("Fetch() error = %T, want a StatusError", err)
+			case tt.wantStatus != 0 && serr.StatusCode != tt.wantStatus:
+				t.Errorf("StatusCode = %d, want %d", serr.StatusCode, tt.wantStatus)
+			}
+		})
+	}
+}
@@ -0,0 +1,144 @@
+package checker
+
+import (
+	"hash/fnv"
+	"log/slog"
+	"math"
+	"strings"
+)
+
+// systemKeyspaces are the keyspaces Cassandra keeps for itself.
+var systemKeyspaces = []string{
+	"system",
+	"system_schema",
+	"system_auth",
+	"system_distributed",
+	"system_traces",
+}
+
+// keyspaceTypes are the MBean types with a keyspace segment, the only ones
+// the keyspace filters apply to.
+var keyspaceTypes = []string{"ColumnFamily", "Table", "Keyspace"}
+
+// skipMetric tells whether keyPath is filtered out by name. When Include is
+// set it works as an allowlist and Skip is ignored. Names must match the
+// whole name segment, so skipping Latency leaves ReadLatency alone.
+func skipMetric(keyPath string, cfg Config) bool {
+	name := strings.Trim(segment(keyPath, "name"), `"`)
+	if len(cfg.Include) > 0 {
+		if contains(cfg.Include, name) {
+			return false
+		}
+		slog.Debug("Skipping metric not included", "key_path", keyPath)
+		return true
+	}
+
+	if contains(cfg.Skip, name) {
+		slog.Debug("Skipping metric", "key_path", keyPath, "name", name)
+		return true
+	}
+
+	for _, re := range cfg.SkipRegex {
+		if re.MatchString(name) {
+			slog.Debug("Skipping metric", "key_path", keyPath, "regex", re.String())
+			return true
+		}
+	}
+	return false
+}
+
+// segment returns the value of the key segment of keyPath, or an empty
+// string if there is none.
+func segment(keyPath, key string) string {
+	for _, part := range splitKeyPath(keyPath) {
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) == 2 && kv[0] == key {
+			return kv[1]
+		}
+	}
+	return ""
+}
+
+// skipKeyspace tells whether keyPath is filtered out by its keyspace. The
+// MBean types without a keyspace segment, such as ThreadPools, never are.
+func skipKeyspace(keyPath string, cfg Config) bool {
+	if !contains(keyspaceTypes, segment(keyPath, "type")) {
+		return false
+	}
+	keyspace := segment(keyPath, "keyspace")
+	if len(cfg.Keyspaces) > 0 && !contains(cfg.Keyspaces, keyspace) {
+		slog.Debug("Skipping keyspace not included", "key_path", keyPath, "keyspace", keyspace)
+		return true
+	}
+	if contains(cfg.SkipKeyspaces, keyspace) || (cfg.SkipSystemKeyspaces && contains(systemKeyspaces, keyspace)) {
+		slog.Debug("Skipping keyspace", "key_path", keyPath, "keyspace", keyspace)
+		return true
+	}
+	return false
+}
+
+// skipTable tells whether keyPath is filtered out by its table, the scope
+// segment of the MBean name.
+func skipTable(keyPath string, cfg Config) bool {
+	if scopeTags[segment(keyPath, "type")] != "cf" {
+		return false
+	}
+	table := segment(keyPath, "scope")
+	if len(cfg.Tables) > 0 && !contains(cfg.Tables, table) {
+		slog.Debug("Skipping table not included", "key_path", keyPath, "table", table)
+		return true
+	}
+	if contains(cfg.SkipTables, table) {
+		slog.Debug("Skipping table", "key_path", keyPath, "table", table)
+		return true
+	}
+	return false
+}
+
+// skipField reports whether the attribute key is left out by --fields or
+// --skip-fields.
+func skipField(key string, cfg Config) bool {
+	if len(cfg.Fields) > 0 && !contains(cfg.Fields, key) {
+		return true
+	}
+	return contains(cfg.SkipFields, key)
+}
+
+// skipSample tells whether keyPath is left out by --sample-rate. The choice
+// hashes the MBean name, so the same series are kept on every scrape and
+// every node.
+func skipSample(keyPath string, cfg Config) bool {
+	if cfg.SampleRate <= 0 || cfg.SampleRate >= 1 {
+		return false
+	}
+	h := fnv.New64a()
+	h.Write([]byte(keyPath))
+	return float64(h.Sum64()) >= cfg.SampleRate*math.MaxUint64
+}
+
+// splitKeyPath splits keyPath into its key=value segments. Commas within
+// quoted values, which JMX allows, do not split.
+func splitKeyPath(keyPath string) []string {
+	parts := []string{}
+	quoted := false
+	start := 0
+	for i, c := range keyPath {
+		switch {
+		case c == '"' && (i == 0 || keyPath[i-1] != '\\'):
+			quoted = !quoted
+		case c == ',' && !quoted:
+			parts = append(parts, keyPath[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, keyPath[start:])
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,227 @@
+package checker
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestSkipMetric(t *testing.T) {
+	keyPath := func(name string) string {
+		return "keyspace=app,name=" + name + ",scope=users,type=ColumnFamily"
+	}
+	tests := []struct {
+		name    string
+		cfg     Config
+		metric  string
+		skipped bool
+	}{
+		{"no filters", Config{}, "ReadLatency", false},
+		{"include only, included", Config{Include: []string{"ReadLatency", "WriteLatency"}}, "WriteLatency", false},
+		{"include only, not included", Config{Include: []string{"ReadLatency", "WriteLatency"}}, "LiveDiskSpaceUsed", true},
+		{"skip only, skipped", Config{Skip: []string{"ReadLatency"}}, "ReadLatency", true},
+		{"skip only, not skipped", Config{Skip: []string{"ReadLatency"}}, "WriteLatency", false},
+		{"skip matches the whole name", Config{Skip: []string{"Latency"}}, "ReadLatency", false},
+		{"both, include wins", Config{Include: []string{"ReadLatency"}, Skip: []string{"ReadLatency"}}, "ReadLatency", false},
+		{"both, not included", Config{Include: []string{"ReadLatency"}, Skip: []string{"ReadLatency"}}, "WriteLatency", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := skipMetric(keyPath(tt.metric), tt.cfg); got != tt.skipped {
+				t.Errorf("skipMetric(%s) = %v, want %v", tt.metric, got, tt.skipped)
+			}
+		})
+	}
+}
+
+func TestSkipMetricSubstrings(t *testing.T) {
+	tests := []struct {
+		name    string
+		keyPath string
+		cfg     Config
+		skipped bool
+	}{
+		{"suffix", "keyspace=app,name=ReadLatency,scope=users,type=ColumnFamily", Config{Skip: []string{"Latency"}}, false},
+		{"prefix", "keyspace=app,name=ReadLatency,scope=users,type=ColumnFamily", Config{Skip: []string{"Read"}}, false},
+		{"longer name", "keyspace=app,name=Latency,scope=users,type=ColumnFamily", Config{Skip: []string{"ReadLatency"}}, false},
+		{"exact", "keyspace=app,name=Latency,scope=users,type=ColumnFamily", Config{Skip: []string{"Latency"}}, true},
+		{"name last", "keyspace=app,scope=users,type=ColumnFamily,name=Latency", Config{Skip: []string{"Latency"}}, true},
+		{"other segment value", "keyspace=Latency,name=ReadLatency,scope=Latency,type=ColumnFamily", Config{Skip: []string{"Latency"}}, false},
+		{"other segment key", "keyspace=app,name=ReadLatency,scope=users,subname=Latency,type=ColumnFamily", Config{Skip: []string{"Latency"}}, false},
+		{"include substring", "keyspace=app,name=ReadLatency,scope=users,type=ColumnFamily", Config{Include: []string{"Latency"}}, true},
+		{"quoted name", `keyspace=app,name="Read,Latency",scope=users,type=ColumnFamily`, Config{Skip: []string{"Read,Latency"}}, true},
+		{"quoted name substring", `keyspace=app,name="Read,Latency",scope=users,type=ColumnFamily`, Config{Skip: []string{"Read"}}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := skipMetric(tt.keyPath, tt.cfg); got != tt.skipped {
+				t.Errorf("skipMetric(%s) = %v, want %v", tt.keyPath, got, tt.skipped)
+			}
+		})
+	}
+}
+
+func TestSkipKeyspace(t *testing.T) {
+	keyPath := func(keyspace string) string {
+		return "keyspace=" + keyspace + ",name=ReadLatency,scope=users,type=ColumnFamily"
+	}
+	tests := []struct {
+		name     string
+		cfg      Config
+		keyspace string
+		skipped  bool
+	}{
+		{"no filters", Config{}, "system", false},
+		{"allowed", Config{Keyspaces: []string{"app", "logs"}}, "logs", false},
+		{"not allowed", Config{Keyspaces: []string{"app", "logs"}}, "other", true},
+		{"denied", Config{SkipKeyspaces: []string{"logs"}}, "logs", true},
+		{"not denied", Config{SkipKeyspaces: []string{"logs"}}, "app", false},
+		{"allowed and denied", Config{Keyspaces: []string{"app"}, SkipKeyspaces: []string{"app"}}, "app", true},
+		{"system keyspace", Config{SkipSystemKeyspaces: true}, "system_schema", true},
+		{"application keyspace", Config{SkipSystemKeyspaces: true}, "app", false},
+		{"keyspace with a space", Config{Keyspaces: []string{"my ks"}}, "my ks", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := skipKeyspace(keyPath(tt.keyspace), tt.cfg); got != tt.skipped {
+				t.Errorf("skipKeyspace(%s) = %v, want %v", tt.keyspace, got, tt.skipped)
+			}
+		})
+	}
+}
+
+func TestSkipKeyspaceMBeanTypes(t *testing.T) {
+	value := `{
+		"org.apache.cassandra.metrics:keyspace=app,name=ReadLatency,scope=users,type=ColumnFamily": {"Count": 1},
+		"org.apache.cassandra.metrics:keyspace=logs,name=ReadLatency,scope=events,type=ColumnFamily": {"Count": 1},
+		"org.apache.cassandra.metrics:keyspace=app,name=LiveSSTableCount,type=Keyspace": {"Value": 1},
+		"org.apache.cassandra.metrics:keyspace=logs,name=LiveSSTableCount,type=Keyspace": {"Value": 1},
+		"org.apache.cassandra.metrics:name=ActiveTasks,path=request,scope=MutationStage,type=ThreadPools": {"Value": 1},
+		"org.apache.cassandra.metrics:name=HitRate,scope=KeyCache,type=Cache": {"Value": 1}
+	}`
+	types := []string{"ColumnFamily", "Keyspace", "ThreadPools", "Cache"}
+	tests := []struct {
+		name        string
+		cfg         Config
+		wantSkipped int
+		wantEmitted int
+	}{
+		{"no filters", Config{MBeanTypes: types}, 0, 6},
+		{"keyspace allowlist", Config{MBeanTypes: types, Keyspaces: []string{"app"}}, 2, 4},
+		{"keyspace denylist", Config{MBeanTypes: types, SkipKeyspaces: []string{"app"}}, 2, 4},
+		{"system keyspaces", Config{MBeanTypes: types, SkipSystemKeyspaces: true}, 0, 6},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.cfg.Measurement, tt.cfg.Hostname = "ckc", "node1"
+			resp := &Response{}
+			if err := json.Unmarshal([]byte(`{"status": 200, "timestamp": 1700000000, "value": `+value+`}`), resp); err != nil {
+				t.Fatal(err)
+			}
+			_, stats, err := RenderWithStats(resp, tt.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if stats.SkippedByKeyspace != tt.wantSkipped || stats.Emitted != tt.wantEmitted {
+				t.Errorf("skipped by keyspace %d and emitted %d, want %d and %d",
+					stats.SkippedByKeyspace, stats.Emitted, tt.wantSkipped, tt.wantEmitted)
+			}
+		})
+	}
+}
+
+func TestSkipTable(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     Config
+		keyPath string
+		skipped bool
+	}{
+		{"no filters", Config{}, "keyspace=app,name=ReadLatency,scope=users,type=ColumnFamily", false},
+		{"allowed", Config{Tables: []string{"users", "events"}}, "keyspace=app,name=ReadLatency,scope=events,type=ColumnFamily", false},
+		{"not allowed", Config{Tables: []string{"users", "events"}}, "keyspace=app,name=ReadLatency,scope=lookup,type=ColumnFamily", true},
+		{"denied", Config{SkipTables: []string{"lookup"}}, "keyspace=app,name=ReadLatency,scope=lookup,type=ColumnFamily", true},
+		{"not denied", Config{SkipTables: []string{"lookup"}}, "keyspace=app,name=ReadLatency,scope=users,type=ColumnFamily", false},
+		{"not a table metric", Config{Tables: []string{"users"}}, "keyspace=app,name=LiveSSTableCount,type=Keyspace", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := skipTable(tt.keyPath, tt.cfg); got != tt.skipped {
+				t.Errorf("skipTable(%s) = %v, want %v", tt.keyPath, got, tt.skipped)
+			}
+		})
+	}
+}
+
+func TestSkipField(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     Config
+		key     string
+		skipped bool
+	}{
+		{"no filters", Config{}, "Mean", false},
+		{"whitelisted", Config{Fields: []string{"Count", "Mean"}}, "Mean", false},
+		{"not whitelisted", Config{Fields: []string{"Count", "Mean"}}, "FiveMinuteRate", true},
+		{"blacklisted", Config{SkipFields: []string{"FiveMinuteRate"}}, "FiveMinuteRate", true},
+		{"not blacklisted", Config{SkipFields: []string{"FiveMinuteRate"}}, "Count", false},
+		{"whitelisted and blacklisted", Config{Fields: []string{"Count", "Mean"}, SkipFields: []string{"Mean"}}, "Mean", true},
+		{"exact key", Config{Fields: []string{"Rate"}}, "OneMinuteRate", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := skipField(tt.key, tt.cfg); got != tt.skipped {
+				t.Errorf("skipField(%s) = %v, want %v", tt.key, got, tt.skipped)
+			}
+		})
+	}
+}
+
+func TestSkipSample(t *testing.T) {
+	keyPaths := []string{}
+	for i := 0; i < 1000; i++ {
+		keyPaths = append(keyPaths, fmt.Sprintf("keyspace=app,name=ReadLatency,scope=table%d,type=ColumnFamily", i))
+	}
+	sampled := func(rate float64) map[string]bool {
+		kept := map[string]bool{}
+		for _, keyPath := range keyPaths {
+			if !skipSample(keyPath, Config{SampleRate: rate}) {
+				kept[keyPath] = true
+			}
+		}
+		return kept
+	}
+	tests := []struct {
+		name     string
+		rate     float64
+		min, max int
+	}{
+		{"unset", 0, 1000, 1000},
+		{"all", 1, 1000, 1000},
+		{"quarter", 0.25, 200, 300},
+		{"half", 0.5, 450, 550},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kept := sampled(tt.rate)
+			if len(kept) < tt.min || len(kept) > tt.max {
+				t.Errorf("kept %d series, want between %d and %d", len(kept), tt.min, tt.max)
+			}
+			for i := 0; i < 3; i++ {
+				if again := sampled(tt.rate); !reflect.DeepEqual(again, kept) {
+					t.Fatalf("run %d kept %d series, not the same %d as the first run", i+2, len(again), len(kept))
+				}
+			}
+		})
+	}
+
+	// A higher rate keeps a superset, so raising it does not reshuffle the
+	// series already sampled.
+	half := sampled(0.5)
+	for keyPath := range sampled(0.25) {
+		if !half[keyPath] {
+			t.Errorf("%s kept at 0.25 but not at 0.5", keyPath)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package checker
+
+import "log/slog"
+
+// maxFlattenDepth bounds how deep composite values are flattened, so a
+// self-referencing attribute cannot blow up the number of fields.
+const maxFlattenDepth = 8
+
+// flattenValues returns values with the composite (map) values replaced by
+// their leaves, keyed by the dotted path to them, such as
+// `GCStats.CollectionCount`. Leaves deeper than maxFlattenDepth are dropped.
+func flattenValues(values map[string]interface{}) map[string]interface{} {
+	flat := map[string]interface{}{}
+	flattenInto(flat, "", values, 1)
+	return flat
+}
+
+func flattenInto(flat map[string]interface{}, prefix string, values map[string]interface{}, depth int) {
+	for key, value := range values {
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		nested, ok := value.(map[string]interface{})
+		if !ok {
+			flat[key] = value
+			continue
+		}
+		if depth >= maxFlattenDepth {
+			slog.Debug("Ignoring composite value nested too deep", "field", key, "max_depth", maxFlattenDepth)
+			continue
+		}
+		flattenInto(flat, key, nested, depth+1)
+	}
+}
@@ -0,0 +1,65 @@
+package checker
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// nestedValue returns a leaf nested under depth composite values.
+func nestedValue(depth int) map[string]interface{} {
+	value := map[string]interface{}{"Leaf": 1}
+	for i := 0; i < depth; i++ {
+		value = map[string]interface{}{"Level": value}
+	}
+	return value
+}
+
+func TestFlattenValues(t *testing.T) {
+	tests := []struct {
+		name   string
+		values map[string]interface{}
+		want   map[string]interface{}
+	}{
+		{"scalars", map[string]interface{}{"Count": 3, "Unit": "ms"}, map[string]interface{}{"Count": 3, "Unit": "ms"}},
+		{"two levels", map[string]interface{}{
+			"Count":   3,
+			"GCStats": map[string]interface{}{"CollectionCount": 5, "Last": map[string]interface{}{"Duration": 12}},
+		}, map[string]interface{}{"Count": 3, "GCStats.CollectionCount": 5, "GCStats.Last.Duration": 12}},
+		{"empty composite", map[string]interface{}{"Count": 3, "GCStats": map[string]interface{}{}}, map[string]interface{}{"Count": 3}},
+		{"deepest kept", nestedValue(maxFlattenDepth - 1), map[string]interface{}{strings.Repeat("Level.", maxFlattenDepth-1) + "Leaf": 1}},
+		{"too deep", nestedValue(maxFlattenDepth), map[string]interface{}{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := flattenValues(tt.values); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("flattenValues() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderFlattenComposite(t *testing.T) {
+	value := `{"org.apache.cassandra.metrics:keyspace=app,name=ReadLatency,scope=users,type=ColumnFamily": {"Count": 3, "GCStats": {"CollectionCount": 5, "Last": {"Duration": 1.5}}}}`
+	tests := []struct {
+		name string
+		cfg  Config
+		want string
+	}{
+		{"dropped", Config{Measurement: "ckc", Hostname: "node1"},
+			"ckc,cf=users,host=node1,keyspace=app,metric=ReadLatency Count=3i 1700000000000000000"},
+		{"flattened", Config{Measurement: "ckc", Hostname: "node1", FlattenComposite: true},
+			"ckc,cf=users,host=node1,keyspace=app,metric=ReadLatency Count=3i,GCStats.CollectionCount=5i,GCStats.Last.Duration=1.500000 1700000000000000000"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lines, err := renderValue(t, value, tt.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(lines) != 1 || lines[0] != tt.want {
+				t.Errorf("Render() = %q, want %q", lines, tt.want)
+			}
+		})
+	}
+}
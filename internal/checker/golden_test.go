@@ -0,0 +1,143 @@
+package checker
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+// readResponse decodes a jolokia response from testdata.
+func readResponse(t testing.TB, name string) *Response {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp := &Response{}
+	if err := json.Unmarshal(data, resp); err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+// TestGolden guards the output bytes of every format against unintended
+// changes. Run with -update to accept a change.
+func TestGolden(t *testing.T) {
+	for _, format := range []string{"influx", "prometheus", "graphite", "json", "opentsdb", "statsd", "table"} {
+		t.Run(format, func(t *testing.T) {
+			cfg := Config{Measurement: "cassandra", Hostname: "node1", OutputFormat: format, GraphitePrefix: "cassandra"}
+			lines, err := Render(readResponse(t, "response.json"), cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := strings.Join(lines, "\n") + "\n"
+
+			golden := filepath.Join("testdata", format+".golden")
+			if *update {
+				if err := os.WriteFile(golden, []byte(got), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != string(want) {
+				t.Errorf("output differs from %s:\ngot:\n%s\nwant:\n%s", golden, got, want)
+			}
+		})
+	}
+}
+
+// syntheticResponse returns a response with n table metrics.
+func syntheticResponse(n int) *Response {
+	resp := &Response{Status: 200, TimeStamp: 1700000000, Value: map[string]map[string]interface{}{}}
+	for i := 0; i < n; i++ {
+		keyPath := fmt.Sprintf("org.apache.cassandra.metrics:keyspace=ks%d,name=ReadLatency,scope=table%d,type=ColumnFamily", i%100, i)
+		resp.Value[keyPath] = map[string]interface{}{
+			"99thPercentile": json.Number("1131.752"),
+			"Count":          json.Number("9007199254740993"),
+			"DurationUnit":   "microseconds",
+			"Mean":           json.Number("155.56"),
+		}
+	}
+	return resp
+}
+
+func BenchmarkRender(b *testing.B) {
+	resp := syntheticResponse(50000)
+	cfg := Config{Measurement: "cassandra", Hostname: "node1"}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := Render(resp, cfg); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkInfluxLines(b *testing.B) {
+	cfg := Config{Measurement: "cassandra", Hostname: "node1"}
+	metrics, _, err := collectAll([]*Response{syntheticResponse(50000)}, cfg)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		influxLines(metrics, cfg)
+	}
+}
+
+// TestRenderWorkers checks that rendering with several workers gives the
+// output of a single one. Run it with -race, as it also renders
+// concurrently with shared rates, deltas, changes and activity.
+func TestRenderWorkers(t *testing.T) {
+	resp := syntheticResponse(2000)
+	for _, format := range []string{"influx", "prometheus", "graphite", "json", "opentsdb", "statsd", "table"} {
+		t.Run(format, func(t *testing.T) {
+			cfg := Config{Measurement: "cassandra", Hostname: "node1", OutputFormat: format, Workers: 1}
+			want, err := Render(resp, cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			cfg.Workers = 8
+			got, err := Render(resp, cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Join(got, "\n") != strings.Join(want, "\n") {
+				t.Errorf("Render() with 8 workers differs from a single one")
+			}
+		})
+	}
+
+	cfg := Config{
+		Measurement: "cassandra",
+		Hostname:    "node1",
+		Workers:     8,
+		Rates:       NewRates(),
+		Deltas:      NewDeltas([]string{"Count"}),
+		Changes:     NewChanges(time.Minute),
+		Activity:    NewActivity(),
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := Render(resp, cfg); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,49 @@
+package checker
+
+import (
+	"fmt"
+	"strings"
+)
+
+// graphiteEscaper also drops the brackets of IPv6 nodes, which graphite
+// would take as a wildcard.
+var graphiteEscaper = strings.NewReplacer(".", "_", " ", "_", "[", "", "]", "")
+
+// graphiteLines renders the metrics in the Graphite plaintext protocol, as
+// `<prefix>.<host>.<keyspace>.<cf>.<metric>.<field> <value> <timestamp>`.
+// String fields have no Graphite representation and are left out.
+func graphiteLines(metrics []metric, cfg Config) []string {
+	lines := []string{}
+	for _, m := range metrics {
+		path := graphitePath(cfg)
+		for _, t := range m.tags {
+			if t.key != "metric" {
+				path = append(path, graphiteEscaper.Replace(t.value))
+			}
+		}
+		path = append(path, graphiteEscaper.Replace(m.name))
+
+		for _, f := range m.fields {
+			value, ok := numberString(f.value)
+			if !ok {
+				continue
+			}
+			lines = append(lines, fmt.Sprintf("%s.%s %s %d",
+				strings.Join(path, "."), graphiteEscaper.Replace(f.key), value, m.timestamp.Unix()))
+		}
+	}
+	return lines
+}
+
+// graphitePath returns the start of every path, the prefix followed by the
+// host and the other static tag values.
+func graphitePath(cfg Config) []string {
+	path := []string{}
+	if cfg.GraphitePrefix != "" {
+		path = append(path, cfg.GraphitePrefix)
+	}
+	for _, t := range staticTags(cfg) {
+		path = append(path, graphiteEscaper.Replace(t.value))
+	}
+	return path
+}
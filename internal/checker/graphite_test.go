@@ -0,0 +1,44 @@
+package checker
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGraphiteLines(t *testing.T) {
+	value := `{
+		"org.apache.cassandra.metrics:keyspace=app,name=ReadLatency,scope=users.v2,type=ColumnFamily": {"Count": 3, "DurationUnit": "microseconds", "Mean": 1.5},
+		"org.apache.cassandra.metrics:keyspace=app,name=WriteLatency,scope=events,type=ColumnFamily": {"Count": 0}
+	}`
+	tests := []struct {
+		name string
+		cfg  Config
+		want []string
+	}{
+		{"prefix", Config{OutputFormat: "graphite", GraphitePrefix: "cassandra", Hostname: "node1.example.com"}, []string{
+			"cassandra.node1_example_com.app.users_v2.ReadLatency.Count 3 1700000000",
+			"cassandra.node1_example_com.app.users_v2.ReadLatency.Mean 1.5 1700000000",
+			"cassandra.node1_example_com.app.events.WriteLatency.Count 0 1700000000",
+		}},
+		{"no prefix", Config{OutputFormat: "graphite", Hostname: "node1"}, []string{
+			"node1.app.users_v2.ReadLatency.Count 3 1700000000",
+			"node1.app.users_v2.ReadLatency.Mean 1.5 1700000000",
+			"node1.app.events.WriteLatency.Count 0 1700000000",
+		}},
+		{"skip zeros", Config{OutputFormat: "graphite", GraphitePrefix: "cassandra", Hostname: "node1", SkipZeros: true}, []string{
+			"cassandra.node1.app.users_v2.ReadLatency.Count 3 1700000000",
+			"cassandra.node1.app.users_v2.ReadLatency.Mean 1.5 1700000000",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lines, err := renderValue(t, value, tt.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Join(lines, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("Render() = %q, want %q", lines, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,149 @@
+package checker
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// heartbeatName prefixes the metrics the checker reports about itself.
+const heartbeatName = "cassandra_keyspaces_checker"
+
+// ScrapeStatus is the outcome of scraping one jolokia agent.
+type ScrapeStatus struct {
+	Node        string
+	Tags        map[string]string
+	Up          bool
+	Duration    time.Duration
+	SeriesCount int
+	// RequestDuration is the time spent in the HTTP requests to jolokia.
+	RequestDuration time.Duration
+	// CardinalityExceeded tells whether the scrape hit --max-series.
+	CardinalityExceeded bool
+}
+
+// Heartbeat renders the metrics reporting whether each scrape succeeded, how
+// long it took and how many series it emitted, so a failed scrape is not
+// just a gap in the data.
+func Heartbeat(cfg Config, statuses []ScrapeStatus, timestamp time.Time) []string {
+	type sample struct {
+		name   string
+		values []int64
+	}
+	samples := []sample{{name: "up"}, {name: "scrape_duration_ms"}, {name: "series_count"}}
+	if cfg.EmitScrapeTiming {
+		samples = append(samples, sample{name: "jolokia_ms"})
+	}
+	if cfg.MaxSeries > 0 {
+		samples = append(samples, sample{name: "cardinality_exceeded"})
+	}
+	for _, status := range statuses {
+		up := int64(0)
+		if status.Up {
+			up = 1
+		}
+		samples[0].values = append(samples[0].values, up)
+		samples[1].values = append(samples[1].values, status.Duration.Nanoseconds()/int64(time.Millisecond))
+		samples[2].values = append(samples[2].values, int64(status.SeriesCount))
+		if cfg.EmitScrapeTiming {
+			samples[3].values = append(samples[3].values, status.RequestDuration.Nanoseconds()/int64(time.Millisecond))
+		}
+		if cfg.MaxSeries > 0 {
+			exceeded := int64(0)
+			if status.CardinalityExceeded {
+				exceeded = 1
+			}
+			samples[len(samples)-1].values = append(samples[len(samples)-1].values, exceeded)
+		}
+	}
+
+	sort.Slice(samples, func(i, j int) bool { return samples[i].name < samples[j].name })
+
+	lines := []string{}
+	switch cfg.OutputFormat {
+	case "table":
+		// The table is read by a person, who gets the failures logged.
+	case "graphite":
+		for i, status := range statuses {
+			path := graphitePath(cfg)
+			if status.Node != "" {
+				path = append(path, graphiteEscaper.Replace(status.Node))
+			}
+			path = append(path, heartbeatName)
+			for _, s := range samples {
+				lines = append(lines, fmt.Sprintf("%s.%s %d %d",
+					strings.Join(path, "."), s.name, s.values[i], timestamp.Unix()))
+			}
+		}
+	case "opentsdb":
+		for i, status := range statuses {
+			tags := statusTags(status)
+			for _, s := range samples {
+				lines = append(lines, openTSDBLine(heartbeatName+"."+s.name, timestamp,
+					fmt.Sprintf("%d", s.values[i]), tags, cfg))
+			}
+		}
+	case "statsd":
+		for i, status := range statuses {
+			for _, s := range samples {
+				lines = append(lines, statsdLine(heartbeatName+"."+s.name,
+					fmt.Sprintf("%d", s.values[i]), statusTags(status), cfg))
+			}
+		}
+	case "json":
+		for i, status := range statuses {
+			tags := statusTags(status)
+			fields := []field{}
+			for _, s := range samples {
+				fields = append(fields, field{s.name, s.values[i]})
+			}
+			if line, ok := jsonLine(heartbeatName, tags, fields, timestamp, cfg); ok {
+				lines = append(lines, line)
+			}
+		}
+	case "prometheus":
+		for _, s := range samples {
+			name := heartbeatName + "_" + s.name
+			lines = append(lines, "# TYPE "+name+" gauge")
+			for i, status := range statuses {
+				labels := []string{}
+				for _, t := range heartbeatTags(cfg, status) {
+					labels = append(labels, prometheusLabel(t.key, t.value))
+				}
+				lines = append(lines, fmt.Sprintf("%s{%s} %d", name, strings.Join(labels, ","), s.values[i]))
+			}
+		}
+	default:
+		for i, status := range statuses {
+			key := heartbeatName
+			for _, t := range heartbeatTags(cfg, status) {
+				key += "," + escapeTag(t.key) + "=" + escapeTag(t.value)
+			}
+			suffix := "i"
+			if cfg.NoIntegerSuffix {
+				suffix = ""
+			}
+			fields := []string{}
+			for _, s := range samples {
+				fields = append(fields, fmt.Sprintf("%s=%d%s", s.name, s.values[i], suffix))
+			}
+			lines = append(lines, fmt.Sprintf("%s %s %d", key, strings.Join(fields, ","), influxTimestamp(timestamp, cfg)))
+		}
+	}
+	return lines
+}
+
+// heartbeatTags returns the static tags and those of status, sorted.
+func heartbeatTags(cfg Config, status ScrapeStatus) []tag {
+	return sortTags(append(staticTags(cfg), statusTags(status)...))
+}
+
+// statusTags returns the node and the tags of status.
+func statusTags(status ScrapeStatus) []tag {
+	tags := []tag{}
+	if status.Node != "" {
+		tags = append(tags, tag{"node", status.Node})
+	}
+	return append(tags, mapTags(status.Tags)...)
+}
@@ -0,0 +1,65 @@
+package checker
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHeartbeat(t *testing.T) {
+	timestamp := time.Unix(1700000000, 0)
+	tests := []struct {
+		name     string
+		cfg      Config
+		statuses []ScrapeStatus
+		want     []string
+	}{
+		{"success", Config{Hostname: "node1"}, []ScrapeStatus{{Up: true, Duration: 1500 * time.Millisecond, SeriesCount: 42}}, []string{
+			"cassandra_keyspaces_checker,host=node1 scrape_duration_ms=1500i,series_count=42i,up=1i 1700000000000000000",
+		}},
+		{"failure", Config{Hostname: "node1"}, []ScrapeStatus{{Up: false, Duration: 10 * time.Second}}, []string{
+			"cassandra_keyspaces_checker,host=node1 scrape_duration_ms=10000i,series_count=0i,up=0i 1700000000000000000",
+		}},
+		{"per node", Config{Hostname: "checker"}, []ScrapeStatus{{Node: "a", Up: true, SeriesCount: 1}, {Node: "b", Up: false}}, []string{
+			"cassandra_keyspaces_checker,host=checker,node=a scrape_duration_ms=0i,series_count=1i,up=1i 1700000000000000000",
+			"cassandra_keyspaces_checker,host=checker,node=b scrape_duration_ms=0i,series_count=0i,up=0i 1700000000000000000",
+		}},
+		{"prometheus", Config{Hostname: "node1", OutputFormat: "prometheus"}, []ScrapeStatus{{Up: false}}, []string{
+			"# TYPE cassandra_keyspaces_checker_scrape_duration_ms gauge",
+			`cassandra_keyspaces_checker_scrape_duration_ms{host="node1"} 0`,
+			"# TYPE cassandra_keyspaces_checker_series_count gauge",
+			`cassandra_keyspaces_checker_series_count{host="node1"} 0`,
+			"# TYPE cassandra_keyspaces_checker_up gauge",
+			`cassandra_keyspaces_checker_up{host="node1"} 0`,
+		}},
+		{"table", Config{Hostname: "node1", OutputFormat: "table"}, []ScrapeStatus{{Up: false}}, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Heartbeat(tt.cfg, tt.statuses, timestamp); strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("Heartbeat() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHeartbeatHostTag(t *testing.T) {
+	timestamp := time.Unix(1700000000, 0)
+	statuses := []ScrapeStatus{{Up: true}}
+	tests := []struct {
+		name string
+		cfg  Config
+		want string
+	}{
+		{"default", Config{Hostname: "node1"}, "cassandra_keyspaces_checker,host=node1 "},
+		{"renamed", Config{Hostname: "node1", HostTagKey: "source_host"}, "cassandra_keyspaces_checker,source_host=node1 "},
+		{"omitted", Config{Hostname: "node1", NoHostTag: true}, "cassandra_keyspaces_checker "},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Heartbeat(tt.cfg, statuses, timestamp); len(got) != 1 || !strings.HasPrefix(got[0], tt.want) {
+				t.Errorf("Heartbeat() = %q, want a line starting with %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,127 @@
+package checker
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// percentiles computed from histogram buckets, keyed by their field suffix.
+var percentiles = []struct {
+	suffix string
+	value  float64
+}{
+	{"p50", 0.5},
+	{"p75", 0.75},
+	{"p95", 0.95},
+	{"p99", 0.99},
+	{"p999", 0.999},
+}
+
+// histogram is the buckets of a Cassandra EstimatedHistogram attribute.
+type histogram struct {
+	key     string
+	buckets []int64
+}
+
+// parseBuckets converts the values of a histogram attribute into buckets.
+func parseBuckets(key string, values []interface{}) ([]int64, error) {
+	buckets := make([]int64, len(values))
+	for i, value := range values {
+		n, ok := value.(json.Number)
+		if !ok {
+			return nil, fmt.Errorf("bucket %d of `%s` is not a number: %v", i, key, value)
+		}
+		v, err := n.Float64()
+		if err != nil {
+			return nil, fmt.Errorf("bucket %d of `%s` is not a number: %v", i, key, value)
+		}
+		buckets[i] = int64(v)
+	}
+	return buckets, nil
+}
+
+// histogramFields returns one field per percentile of the buckets of a
+// Cassandra EstimatedHistogram, named after key.
+func histogramFields(key string, buckets []int64) []field {
+	fields := []field{}
+	for _, p := range percentiles {
+		fields = append(fields, field{key + "_" + p.suffix, histogramPercentile(buckets, p.value)})
+	}
+	return fields
+}
+
+// prometheusHistogram renders the buckets as a prometheus histogram, with
+// cumulative buckets bounded by the EstimatedHistogram offsets. The last
+// bucket counts overflowed values, so it only appears in the +Inf bucket
+// and _count; _sum is estimated from the bucket bounds.
+func prometheusHistogram(name, labels string, buckets []int64) []string {
+	if len(buckets) < 2 {
+		return nil
+	}
+	if labels != "" {
+		labels += ","
+	}
+	offsets := bucketOffsets(len(buckets) - 1)
+
+	lines := []string{}
+	var count, sum int64
+	for i, offset := range offsets {
+		count += buckets[i]
+		sum += buckets[i] * offset
+		lines = append(lines, fmt.Sprintf(`%s_bucket{%sle="%d"} %d`, name, labels, offset, count))
+	}
+	count += buckets[len(buckets)-1]
+	lines = append(lines,
+		fmt.Sprintf(`%s_bucket{%sle="+Inf"} %d`, name, labels, count),
+		fmt.Sprintf(`%s_sum{%s} %d`, name, strings.TrimSuffix(labels, ","), sum),
+		fmt.Sprintf(`%s_count{%s} %d`, name, strings.TrimSuffix(labels, ","), count))
+	return lines
+}
+
+// histogramPercentile mirrors EstimatedHistogram.percentile: it returns the
+// upper bound of the bucket holding the given percentile. The last bucket
+// counts overflowed values and is ignored.
+func histogramPercentile(buckets []int64, percentile float64) int64 {
+	if len(buckets) < 2 {
+		return 0
+	}
+	offsets := bucketOffsets(len(buckets) - 1)
+	lastBucket := len(buckets) - 1
+
+	var count int64
+	for _, b := range buckets[:lastBucket] {
+		count += b
+	}
+	pcount := int64(math.Ceil(float64(count) * percentile))
+	if pcount == 0 {
+		return 0
+	}
+
+	var elements int64
+	for i := 0; i < lastBucket; i++ {
+		elements += buckets[i]
+		if elements >= pcount {
+			return offsets[i]
+		}
+	}
+	return 0
+}
+
+// bucketOffsets mirrors EstimatedHistogram.newOffsets, where each bucket
+// grows by 20% over the previous one.
+func bucketOffsets(size int) []int64 {
+	offsets := make([]int64, size)
+	last := int64(1)
+	offsets[0] = last
+	for i := 1; i < size; i++ {
+		next := int64(math.Floor(float64(last)*1.2 + 0.5))
+		if next == last {
+			next++
+		}
+		offsets[i] = next
+		last = next
+	}
+	return offsets
+}
@@ -0,0 +1,120 @@
+package checker
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// testBuckets hold 100 values below the offsets 1, 2, 3, 4, 5, 6, 7, 8, 10
+// and 12, plus 7 overflowed ones.
+var testBuckets = []int64{0, 10, 0, 0, 50, 30, 5, 4, 1, 0, 7}
+
+func TestBucketOffsets(t *testing.T) {
+	want := []int64{1, 2, 3, 4, 5, 6, 7, 8, 10, 12, 14, 17, 20, 24, 29, 35}
+	if got := bucketOffsets(len(want)); !reflect.DeepEqual(got, want) {
+		t.Errorf("bucketOffsets() = %v, want %v", got, want)
+	}
+}
+
+func TestHistogramPercentile(t *testing.T) {
+	tests := []struct {
+		name       string
+		buckets    []int64
+		percentile float64
+		want       int64
+	}{
+		{"p50", testBuckets, 0.5, 5},
+		{"p75", testBuckets, 0.75, 6},
+		{"p95", testBuckets, 0.95, 7},
+		{"p99", testBuckets, 0.99, 8},
+		{"p999", testBuckets, 0.999, 10},
+		{"empty", []int64{0, 0, 0, 0}, 0.5, 0},
+		{"only overflowed", []int64{0, 0, 0, 9}, 0.5, 0},
+		{"too short", []int64{5}, 0.5, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := histogramPercentile(tt.buckets, tt.percentile); got != tt.want {
+				t.Errorf("histogramPercentile() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderHistograms(t *testing.T) {
+	value := `{"org.apache.cassandra.metrics:keyspace=app,name=ReadLatency,scope=users,type=ColumnFamily": {"Count": 3, "RecentValues": [0, 10, 0, 0, 50, 30, 5, 4, 1, 0, 7]}}`
+	tests := []struct {
+		name string
+		cfg  Config
+		want string
+	}{
+		{"dropped", Config{Measurement: "ckc", Hostname: "node1"}, "ckc,cf=users,host=node1,keyspace=app,metric=ReadLatency Count=3i 1700000000000000000"},
+		{"percentiles", Config{Measurement: "ckc", Hostname: "node1", Histograms: true}, "ckc,cf=users,host=node1,keyspace=app,metric=ReadLatency Count=3i,RecentValues_p50=5i,RecentValues_p75=6i,RecentValues_p95=7i,RecentValues_p99=8i,RecentValues_p999=10i 1700000000000000000"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lines, err := renderValue(t, value, tt.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(lines) != 1 || lines[0] != tt.want {
+				t.Errorf("Render() = %q, want %q", lines, tt.want)
+			}
+		})
+	}
+}
+
+func TestPrometheusHistogram(t *testing.T) {
+	tests := []struct {
+		name    string
+		labels  string
+		buckets []int64
+		want    []string
+	}{
+		{"cumulative", `host="node1"`, []int64{1, 2, 0, 3, 4}, []string{
+			`h_bucket{host="node1",le="1"} 1`,
+			`h_bucket{host="node1",le="2"} 3`,
+			`h_bucket{host="node1",le="3"} 3`,
+			`h_bucket{host="node1",le="4"} 6`,
+			`h_bucket{host="node1",le="+Inf"} 10`,
+			`h_sum{host="node1"} 17`,
+			`h_count{host="node1"} 10`,
+		}},
+		{"no labels", "", []int64{2, 0}, []string{
+			`h_bucket{le="1"} 2`,
+			`h_bucket{le="+Inf"} 2`,
+			`h_sum{} 2`,
+			`h_count{} 2`,
+		}},
+		{"too short", `host="node1"`, []int64{5}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := prometheusHistogram("h", tt.labels, tt.buckets); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("prometheusHistogram() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderPrometheusHistograms(t *testing.T) {
+	value := `{"org.apache.cassandra.metrics:keyspace=app,name=ReadLatency,scope=users,type=ColumnFamily": {"RecentValues": [0, 10, 0, 0, 50, 30, 5, 4, 1, 0, 7]}}`
+	lines, err := renderValue(t, value, Config{Hostname: "node1", OutputFormat: "prometheus", PrometheusHistograms: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	const name, labels = "cassandra_columnfamily_readlatency_recentvalues", `cf="users",host="node1",keyspace="app"`
+	want := []string{"# TYPE " + name + " histogram"}
+	buckets := []struct {
+		le    string
+		count int
+	}{{"1", 0}, {"2", 10}, {"3", 10}, {"4", 10}, {"5", 60}, {"6", 90}, {"7", 95}, {"8", 99}, {"10", 100}, {"12", 100}, {"+Inf", 107}}
+	for _, b := range buckets {
+		want = append(want, fmt.Sprintf(`%s_bucket{%s,le="%s"} %d`, name, labels, b.le, b.count))
+	}
+	want = append(want, name+"_sum{"+labels+"} 527", name+"_count{"+labels+"} 107")
+	if !reflect.DeepEqual(lines, want) {
+		t.Errorf("Render() = %q, want %q", lines, want)
+	}
+}
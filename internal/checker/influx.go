@@ -0,0 +1,133 @@
+package checker
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	measurementEscaper = strings.NewReplacer(`,`, `\,`, ` `, `\ `)
+	tagEscaper         = strings.NewReplacer(`,`, `\,`, ` `, `\ `, `=`, `\=`)
+	fieldStringEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+)
+
+// escapeMeasurement escapes a measurement name as per the line protocol.
+func escapeMeasurement(s string) string {
+	return measurementEscaper.Replace(s)
+}
+
+// escapeTag escapes tag keys, tag values and field keys as per the line
+// protocol.
+func escapeTag(s string) string {
+	return tagEscaper.Replace(s)
+}
+
+// escapeFieldString escapes the contents of a quoted string field value.
+func escapeFieldString(s string) string {
+	return fieldStringEscaper.Replace(s)
+}
+
+func influxLines(metrics []metric, cfg Config) []string {
+	measurement := cfg.Measurement
+	if measurement == "" {
+		measurement = cfg.Name
+	}
+	measurement = escapeMeasurement(measurement)
+
+	// The buffer is reused across lines, as large responses have tens of
+	// thousands of them.
+	var buf bytes.Buffer
+	lines := make([]string, 0, len(metrics))
+	for _, m := range metrics {
+		buf.Reset()
+		tags := append(staticTags(cfg), m.tags...)
+		if info, ok := knownMetrics[m.name]; ok && cfg.EmitTypeTags {
+			tags = append(tags, tag{"metric_type", info.kind})
+			if info.unit != "" {
+				tags = append(tags, tag{"unit", info.unit})
+			}
+		}
+		buf.WriteString(measurement)
+		for _, t := range sortTags(tags) {
+			buf.WriteByte(',')
+			buf.WriteString(escapeTag(t.key))
+			buf.WriteByte('=')
+			buf.WriteString(escapeTag(t.value))
+		}
+
+		fields := 0
+		for _, f := range m.fields {
+			if v, ok := f.value.(float64); ok && (math.IsNaN(v) || math.IsInf(v, 0)) {
+				// The line protocol has no representation for these.
+				continue
+			}
+			if fields == 0 {
+				buf.WriteByte(' ')
+			} else {
+				buf.WriteByte(',')
+			}
+			fields++
+			buf.WriteString(escapeTag(f.key))
+			buf.WriteByte('=')
+			switch v := f.value.(type) {
+			case string:
+				buf.WriteByte('"')
+				buf.WriteString(escapeFieldString(v))
+				buf.WriteByte('"')
+			case bool:
+				buf.WriteString(strconv.FormatBool(v))
+			case float64:
+				buf.WriteString(influxFloat(v, 64, cfg))
+			case int64:
+				buf.WriteString(strconv.FormatInt(v, 10))
+				if !cfg.NoIntegerSuffix {
+					buf.WriteByte('i')
+				}
+			case float32:
+				buf.WriteString(influxFloat(float64(v), 32, cfg))
+			case complex64, complex128:
+				fmt.Fprintf(&buf, "%f", v)
+			default:
+				fmt.Fprintf(&buf, "%d", v)
+				if !cfg.NoIntegerSuffix {
+					buf.WriteByte('i')
+				}
+			}
+		}
+
+		if fields == 0 {
+			continue
+		}
+		buf.WriteByte(' ')
+		buf.WriteString(strconv.FormatInt(influxTimestamp(m.timestamp, cfg), 10))
+		lines = append(lines, buf.String())
+	}
+	return lines
+}
+
+// influxFloat formats v with six decimals, or with the shortest
+// representation that reads back to v under --compact-floats.
+func influxFloat(v float64, bitSize int, cfg Config) string {
+	if cfg.CompactFloats {
+		return strconv.FormatFloat(v, 'g', -1, bitSize)
+	}
+	return strconv.FormatFloat(v, 'f', 6, bitSize)
+}
+
+// influxTimestamp returns timestamp in the unit of --timestamp-precision,
+// which must match the precision the consumer is configured for.
+func influxTimestamp(timestamp time.Time, cfg Config) int64 {
+	switch cfg.TimestampPrecision {
+	case "us":
+		return timestamp.UnixMicro()
+	case "ms":
+		return timestamp.UnixMilli()
+	case "s":
+		return timestamp.Unix()
+	}
+	return timestamp.UnixNano()
+}
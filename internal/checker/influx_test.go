@@ -0,0 +1,195 @@
+package checker
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEscapeTag(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"users", "users"},
+		{"my ks", `my\ ks`},
+		{"a,b", `a\,b`},
+		{"a=b", `a\=b`},
+	}
+	for _, tt := range tests {
+		if got := escapeTag(tt.in); got != tt.want {
+			t.Errorf("escapeTag(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestEscapeMeasurement(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"cassandra", "cassandra"},
+		{"my checker", `my\ checker`},
+		{"a,b", `a\,b`},
+		{"a=b", "a=b"},
+	}
+	for _, tt := range tests {
+		if got := escapeMeasurement(tt.in); got != tt.want {
+			t.Errorf("escapeMeasurement(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestEscapeFieldString(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"microseconds", "microseconds"},
+		{`say "hi"`, `say \"hi\"`},
+		{`C:\data`, `C:\\data`},
+	}
+	for _, tt := range tests {
+		if got := escapeFieldString(tt.in); got != tt.want {
+			t.Errorf("escapeFieldString(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestInfluxLinesEscaping(t *testing.T) {
+	value := `{"org.apache.cassandra.metrics:keyspace=my ks,name=ReadLatency,scope=a=b,type=ColumnFamily": {"Count": 3, "Unit": "say \"hi\""}}`
+	lines, err := renderValue(t, value, Config{Measurement: "my checker", Hostname: "node1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `my\ checker,cf=a\=b,host=node1,keyspace=my\ ks,metric=ReadLatency Count=3i,Unit="say \"hi\"" 1700000000000000000`
+	if len(lines) != 1 || lines[0] != want {
+		t.Errorf("influxLines() = %q, want %q", lines, want)
+	}
+}
+
+func TestInfluxMeasurement(t *testing.T) {
+	value := `{"org.apache.cassandra.metrics:keyspace=app,name=ReadLatency,scope=users,type=ColumnFamily": {"Count": 3}}`
+	tests := []struct {
+		name string
+		cfg  Config
+		want string
+	}{
+		{"name", Config{Name: "cassandra-check"}, "cassandra-check"},
+		{"measurement", Config{Measurement: "cassandra"}, "cassandra"},
+		{"measurement over name", Config{Name: "cassandra-check", Measurement: "cassandra"}, "cassandra"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.cfg.NoHostTag = true
+			lines, err := renderValue(t, value, tt.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := tt.want + ",cf=users,keyspace=app,metric=ReadLatency Count=3i 1700000000000000000"
+			if len(lines) != 1 || lines[0] != want {
+				t.Errorf("Render() = %q, want %q", lines, want)
+			}
+		})
+	}
+}
+
+func TestInfluxIntegerSuffix(t *testing.T) {
+	value := `{"org.apache.cassandra.metrics:keyspace=app,name=ReadLatency,scope=users,type=ColumnFamily": {"Count": 3, "Mean": 1.5}}`
+	tests := []struct {
+		name          string
+		cfg           Config
+		want          string
+		wantHeartbeat string
+	}{
+		{"default", Config{Measurement: "ckc", Hostname: "node1"},
+			"ckc,cf=users,host=node1,keyspace=app,metric=ReadLatency Count=3i,Mean=1.500000 1700000000000000000",
+			"cassandra_keyspaces_checker,host=node1 scrape_duration_ms=0i,series_count=1i,up=1i 1700000000000000000"},
+		{"no suffix", Config{Measurement: "ckc", Hostname: "node1", NoIntegerSuffix: true},
+			"ckc,cf=users,host=node1,keyspace=app,metric=ReadLatency Count=3,Mean=1.500000 1700000000000000000",
+			"cassandra_keyspaces_checker,host=node1 scrape_duration_ms=0,series_count=1,up=1 1700000000000000000"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lines, err := renderValue(t, value, tt.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(lines) != 1 || lines[0] != tt.want {
+				t.Errorf("Render() = %q, want %q", lines, tt.want)
+			}
+			heartbeat := Heartbeat(tt.cfg, []ScrapeStatus{{Up: true, SeriesCount: 1}}, time.Unix(1700000000, 0))
+			if len(heartbeat) != 1 || heartbeat[0] != tt.wantHeartbeat {
+				t.Errorf("Heartbeat() = %q, want %q", heartbeat, tt.wantHeartbeat)
+			}
+		})
+	}
+}
+
+func TestInfluxTimestamp(t *testing.T) {
+	timestamp := time.Date(2024, 1, 2, 3, 4, 5, 123456789, time.UTC)
+	tests := []struct {
+		precision string
+		want      int64
+	}{
+		{"", 1704164645123456789},
+		{"ns", 1704164645123456789},
+		{"us", 1704164645123456},
+		{"ms", 1704164645123},
+		{"s", 1704164645},
+	}
+	for _, tt := range tests {
+		t.Run(tt.precision, func(t *testing.T) {
+			if got := influxTimestamp(timestamp, Config{TimestampPrecision: tt.precision}); got != tt.want {
+				t.Errorf("influxTimestamp() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInfluxFloat(t *testing.T) {
+	tests := []struct {
+		value       float64
+		bitSize     int
+		want        string
+		wantCompact string
+	}{
+		{1.5, 64, "1.500000", "1.5"},
+		{1000000.0, 64, "1000000.000000", "1e+06"},
+		{0.1, 64, "0.100000", "0.1"},
+		{0.0000012, 64, "0.000001", "1.2e-06"},
+		{123.456789012, 64, "123.456789", "123.456789012"},
+		{float64(float32(0.1)), 32, "0.100000", "0.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			if got := influxFloat(tt.value, tt.bitSize, Config{}); got != tt.want {
+				t.Errorf("influxFloat() = %s, want %s", got, tt.want)
+			}
+			if got := influxFloat(tt.value, tt.bitSize, Config{CompactFloats: true}); got != tt.wantCompact {
+				t.Errorf("influxFloat() with CompactFloats = %s, want %s", got, tt.wantCompact)
+			}
+		})
+	}
+}
+
+func TestRenderCompactFloats(t *testing.T) {
+	value := `{"org.apache.cassandra.metrics:keyspace=app,name=ReadLatency,scope=users,type=ColumnFamily": {"Count": 3, "Max": 1000000.0, "Mean": 1.5}}`
+	tests := []struct {
+		name string
+		cfg  Config
+		want string
+	}{
+		{"default", Config{Measurement: "ckc", Hostname: "node1"},
+			"ckc,cf=users,host=node1,keyspace=app,metric=ReadLatency Count=3i,Max=1000000.000000,Mean=1.500000 1700000000000000000"},
+		{"compact", Config{Measurement: "ckc", Hostname: "node1", CompactFloats: true},
+			"ckc,cf=users,host=node1,keyspace=app,metric=ReadLatency Count=3i,Max=1e+06,Mean=1.5 1700000000000000000"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lines, err := renderValue(t, value, tt.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(lines) != 1 || lines[0] != tt.want {
+				t.Errorf("Render() = %q, want %q", lines, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package checker
+
+import (
+	"encoding/json"
+	"log/slog"
+	"math"
+	"time"
+)
+
+// jsonSeries is a series in the json output.
+type jsonSeries struct {
+	Measurement string                 `json:"measurement"`
+	Tags        map[string]string      `json:"tags"`
+	Fields      map[string]interface{} `json:"fields"`
+	Timestamp   int64                  `json:"timestamp"`
+}
+
+// jsonLines renders the metrics as one JSON object per series, with the
+// same measurement, tags and fields as the line protocol output.
+func jsonLines(metrics []metric, cfg Config) []string {
+	measurement := cfg.Measurement
+	if measurement == "" {
+		measurement = cfg.Name
+	}
+
+	lines := make([]string, 0, len(metrics))
+	for _, m := range metrics {
+		if line, ok := jsonLine(measurement, m.tags, m.fields, m.timestamp, cfg); ok {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// jsonLine encodes a single series, indented when --json-indent is set.
+// NaN and infinite values, which JSON cannot represent, are left out.
+func jsonLine(measurement string, tags []tag, fields []field, timestamp time.Time, cfg Config) (string, bool) {
+	series := jsonSeries{
+		Measurement: measurement,
+		Tags:        map[string]string{},
+		Fields:      map[string]interface{}{},
+		Timestamp:   timestamp.Unix(),
+	}
+	for _, t := range staticTags(cfg) {
+		series.Tags[t.key] = t.value
+	}
+	for _, t := range tags {
+		series.Tags[t.key] = t.value
+	}
+	for _, f := range fields {
+		if v, ok := f.value.(float64); ok && (math.IsNaN(v) || math.IsInf(v, 0)) {
+			continue
+		}
+		series.Fields[f.key] = f.value
+	}
+	if len(series.Fields) == 0 {
+		return "", false
+	}
+
+	var b []byte
+	var err error
+	if cfg.JSONIndent {
+		b, err = json.MarshalIndent(series, "", "  ")
+	} else {
+		b, err = json.Marshal(series)
+	}
+	if err != nil {
+		slog.Debug("Ignoring series", "measurement", measurement, "error", err)
+		return "", false
+	}
+	return string(b), true
+}
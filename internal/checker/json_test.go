@@ -0,0 +1,60 @@
+package checker
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestJSONLines(t *testing.T) {
+	value := `{
+		"org.apache.cassandra.metrics:keyspace=app,name=ReadLatency,scope=users,type=ColumnFamily": {"Count": 3, "DurationUnit": "microseconds", "Mean": 1.5},
+		"org.apache.cassandra.metrics:keyspace=app,name=WriteLatency,scope=events,type=ColumnFamily": {"Count": 0}
+	}`
+	want := []jsonSeries{
+		{
+			Measurement: "ckc",
+			Tags:        map[string]string{"cf": "users", "host": "node1", "keyspace": "app", "metric": "ReadLatency"},
+			Fields:      map[string]interface{}{"Count": 3.0, "DurationUnit": "microseconds", "Mean": 1.5},
+			Timestamp:   1700000000,
+		},
+		{
+			Measurement: "ckc",
+			Tags:        map[string]string{"cf": "events", "host": "node1", "keyspace": "app", "metric": "WriteLatency"},
+			Fields:      map[string]interface{}{"Count": 0.0},
+			Timestamp:   1700000000,
+		},
+	}
+	tests := []struct {
+		name string
+		cfg  Config
+		want []jsonSeries
+	}{
+		{"compact", Config{OutputFormat: "json", Measurement: "ckc", Hostname: "node1"}, want},
+		{"indented", Config{OutputFormat: "json", Measurement: "ckc", Hostname: "node1", JSONIndent: true}, want},
+		{"skip zeros", Config{OutputFormat: "json", Measurement: "ckc", Hostname: "node1", SkipZeros: true}, want[:1]},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lines, err := renderValue(t, value, tt.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := []jsonSeries{}
+			for _, line := range lines {
+				if strings.Contains(line, "\n") != tt.cfg.JSONIndent {
+					t.Errorf("line %q, want indented %v", line, tt.cfg.JSONIndent)
+				}
+				var series jsonSeries
+				if err := json.Unmarshal([]byte(line), &series); err != nil {
+					t.Fatalf("decoding %q: %v", line, err)
+				}
+				got = append(got, series)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("decoded %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package checker
+
+// metricInfo describes the semantics of a well known Cassandra metric.
+type metricInfo struct {
+	// kind is either counter, gauge or histogram.
+	kind string
+	unit string
+}
+
+// knownMetrics maps metric names to their semantics, as documented by
+// Cassandra.
+var knownMetrics = map[string]metricInfo{
+	"ReadLatency":              {"histogram", "microseconds"},
+	"WriteLatency":             {"histogram", "microseconds"},
+	"RangeLatency":             {"histogram", "microseconds"},
+	"CoordinatorReadLatency":   {"histogram", "microseconds"},
+	"CoordinatorScanLatency":   {"histogram", "microseconds"},
+	"SSTablesPerReadHistogram": {"histogram", "sstables"},
+	"LiveDiskSpaceUsed":        {"gauge", "bytes"},
+	"TotalDiskSpaceUsed":       {"gauge", "bytes"},
+	"MemtableLiveDataSize":     {"gauge", "bytes"},
+	"MemtableOnHeapSize":       {"gauge", "bytes"},
+	"MemtableOffHeapSize":      {"gauge", "bytes"},
+	"LiveSSTableCount":         {"gauge", "sstables"},
+	"PendingCompactions":       {"gauge", "tasks"},
+	"ReadCount":                {"counter", "requests"},
+	"WriteCount":               {"counter", "requests"},
+	"MemtableSwitchCount":      {"counter", "flushes"},
+}
+
+// prometheusType returns the prometheus type of a field of the named
+// metric. Only the Count of counters and histograms keeps growing, every
+// other field is a gauge.
+func prometheusType(name, fieldKey string) string {
+	info, ok := knownMetrics[name]
+	if ok && info.kind != "gauge" && fieldKey == "Count" {
+		return "counter"
+	}
+	return "gauge"
+}
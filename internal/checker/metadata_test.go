@@ -0,0 +1,62 @@
+package checker
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPrometheusType(t *testing.T) {
+	tests := []struct {
+		name, field, want string
+	}{
+		{"ReadLatency", "Count", "counter"},
+		{"ReadLatency", "99thPercentile", "gauge"},
+		{"WriteCount", "Count", "counter"},
+		{"LiveDiskSpaceUsed", "Count", "gauge"},
+		{"UnknownMetric", "Count", "gauge"},
+	}
+	for _, tt := range tests {
+		if got := prometheusType(tt.name, tt.field); got != tt.want {
+			t.Errorf("prometheusType(%s, %s) = %q, want %q", tt.name, tt.field, got, tt.want)
+		}
+	}
+}
+
+func TestRenderTypeAnnotations(t *testing.T) {
+	value := `{
+		"org.apache.cassandra.metrics:keyspace=app,name=LiveDiskSpaceUsed,scope=users,type=ColumnFamily": {"Count": 1024},
+		"org.apache.cassandra.metrics:keyspace=app,name=ReadLatency,scope=users,type=ColumnFamily": {"Count": 3, "Mean": 1.5}
+	}`
+	tests := []struct {
+		name string
+		cfg  Config
+		want []string
+	}{
+		{"prometheus", Config{OutputFormat: "prometheus", Hostname: "node1"}, []string{
+			"# HELP cassandra_columnfamily_livediskspaceused_count LiveDiskSpaceUsed Count of ColumnFamily",
+			"# TYPE cassandra_columnfamily_livediskspaceused_count gauge",
+			`cassandra_columnfamily_livediskspaceused_count{cf="users",host="node1",keyspace="app"} 1024`,
+			"# HELP cassandra_columnfamily_readlatency_count ReadLatency Count of ColumnFamily",
+			"# TYPE cassandra_columnfamily_readlatency_count counter",
+			`cassandra_columnfamily_readlatency_count{cf="users",host="node1",keyspace="app"} 3`,
+			"# HELP cassandra_columnfamily_readlatency_mean ReadLatency Mean of ColumnFamily, in microseconds",
+			"# TYPE cassandra_columnfamily_readlatency_mean gauge",
+			`cassandra_columnfamily_readlatency_mean{cf="users",host="node1",keyspace="app"} 1.5`,
+		}},
+		{"influx type tags", Config{Measurement: "ckc", Hostname: "node1", EmitTypeTags: true}, []string{
+			"ckc,cf=users,host=node1,keyspace=app,metric=LiveDiskSpaceUsed,metric_type=gauge,unit=bytes Count=1024i 1700000000000000000",
+			"ckc,cf=users,host=node1,keyspace=app,metric=ReadLatency,metric_type=histogram,unit=microseconds Count=3i,Mean=1.500000 1700000000000000000",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lines, err := renderValue(t, value, tt.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Join(lines, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("Render() = %q, want %q", lines, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package checker
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+var invalidOpenTSDBChars = regexp.MustCompile(`[^a-zA-Z0-9\-_./]`)
+
+// openTSDBLines renders the metrics as OpenTSDB put commands, as
+// `put cassandra.<type>.<metric>.<field> <timestamp> <value> <tags>`, with
+// the tags sorted by key so the output is stable. String fields have no
+// OpenTSDB representation and are left out.
+func openTSDBLines(metrics []metric, cfg Config) []string {
+	lines := []string{}
+	for _, m := range metrics {
+		tags := []tag{}
+		for _, t := range m.tags {
+			if t.key != "metric" && t.key != "type" {
+				tags = append(tags, t)
+			}
+		}
+		prefix := "cassandra." + openTSDBName(strings.ToLower(m.mbeanType)) + "." + openTSDBName(m.name)
+
+		fields := append([]field{}, m.fields...)
+		sort.Slice(fields, func(i, j int) bool { return fields[i].key < fields[j].key })
+		for _, f := range fields {
+			value, ok := numberString(f.value)
+			if !ok {
+				continue
+			}
+			lines = append(lines, openTSDBLine(prefix+"."+openTSDBName(f.key), m.timestamp, value, tags, cfg))
+		}
+	}
+	return lines
+}
+
+// openTSDBLine formats a put command, adding the static tags to the given
+// ones.
+func openTSDBLine(name string, timestamp time.Time, value string, tags []tag, cfg Config) string {
+	tags = append(staticTags(cfg), tags...)
+	sort.SliceStable(tags, func(i, j int) bool { return tags[i].key < tags[j].key })
+
+	pairs := make([]string, 0, len(tags))
+	for _, t := range tags {
+		pairs = append(pairs, openTSDBName(t.key)+"="+openTSDBName(t.value))
+	}
+	return fmt.Sprintf("put %s %d %s %s", name, timestamp.Unix(), value, strings.Join(pairs, " "))
+}
+
+func openTSDBName(s string) string {
+	return invalidOpenTSDBChars.ReplaceAllString(s, "_")
+}
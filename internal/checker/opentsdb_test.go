@@ -0,0 +1,52 @@
+package checker
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOpenTSDBLine(t *testing.T) {
+	timestamp := time.Unix(1700000000, 0)
+	tests := []struct {
+		name string
+		tags []tag
+		cfg  Config
+		want string
+	}{
+		{"sorted tags", []tag{{"keyspace", "app"}, {"cf", "users"}}, Config{Hostname: "node1"},
+			"put cassandra.columnfamily.ReadLatency.Count 1700000000 3 cf=users host=node1 keyspace=app"},
+		{"static tags", []tag{{"keyspace", "app"}}, Config{Hostname: "node1", Tags: map[string]string{"dc": "eu1"}},
+			"put cassandra.columnfamily.ReadLatency.Count 1700000000 3 dc=eu1 host=node1 keyspace=app"},
+		{"invalid characters", []tag{{"keyspace", "my ks"}, {"cf", "a=b"}}, Config{Hostname: "node1"},
+			"put cassandra.columnfamily.ReadLatency.Count 1700000000 3 cf=a_b host=node1 keyspace=my_ks"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := openTSDBLine("cassandra.columnfamily.ReadLatency.Count", timestamp, "3", tt.tags, tt.cfg); got != tt.want {
+				t.Errorf("openTSDBLine() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOpenTSDBLines(t *testing.T) {
+	value := `{
+		"org.apache.cassandra.metrics:keyspace=app,name=ReadLatency,scope=users,type=ColumnFamily": {"Mean": 1.5, "DurationUnit": "microseconds", "Count": 3},
+		"org.apache.cassandra.metrics:keyspace=app,name=WriteLatency,scope=users,type=ColumnFamily": {"Count": 0}
+	}`
+	want := []string{
+		"put cassandra.columnfamily.ReadLatency.Count 1700000000 3 cf=users host=node1 keyspace=app",
+		"put cassandra.columnfamily.ReadLatency.Mean 1700000000 1.5 cf=users host=node1 keyspace=app",
+	}
+	cfg := Config{OutputFormat: "opentsdb", Hostname: "node1", SkipZeros: true}
+	for i := 0; i < 5; i++ {
+		lines, err := renderValue(t, value, cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Join(lines, "\n") != strings.Join(want, "\n") {
+			t.Fatalf("Render() = %q, want %q", lines, want)
+		}
+	}
+}
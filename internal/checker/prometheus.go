@@ -0,0 +1,77 @@
+package checker
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var invalidPrometheusChars = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+// prometheusLines renders the metrics in the Prometheus text exposition
+// format. Samples carry no timestamp, as the textfile collector rejects them.
+func prometheusLines(metrics []metric, cfg Config) []string {
+	samples := map[string][]string{}
+	types := map[string]string{}
+	help := map[string]string{}
+	for _, m := range metrics {
+		labels := []string{}
+		for _, t := range sortTags(append(staticTags(cfg), m.tags...)) {
+			if t.key == "metric" || t.key == "type" {
+				continue
+			}
+			labels = append(labels, prometheusLabel(t.key, t.value))
+		}
+
+		for _, f := range m.fields {
+			value, ok := numberString(f.value)
+			if !ok {
+				continue
+			}
+			name := "cassandra_" + sanitizePrometheusName(m.mbeanType) + "_" +
+				sanitizePrometheusName(m.name) + "_" + sanitizePrometheusName(f.key)
+			types[name] = prometheusType(m.name, f.key)
+			if info, ok := knownMetrics[m.name]; ok {
+				help[name] = fmt.Sprintf("%s %s of %s", m.name, f.key, m.mbeanType)
+				if info.unit != "" && f.key != "Count" {
+					help[name] += ", in " + info.unit
+				}
+			}
+			samples[name] = append(samples[name],
+				fmt.Sprintf("%s{%s} %s", name, strings.Join(labels, ","), value))
+		}
+
+		for _, h := range m.histograms {
+			name := "cassandra_" + sanitizePrometheusName(m.mbeanType) + "_" +
+				sanitizePrometheusName(m.name) + "_" + sanitizePrometheusName(h.key)
+			types[name] = "histogram"
+			samples[name] = append(samples[name], prometheusHistogram(name, strings.Join(labels, ","), h.buckets)...)
+		}
+	}
+
+	names := make([]string, 0, len(samples))
+	for name := range samples {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	lines := []string{}
+	for _, name := range names {
+		if h, ok := help[name]; ok {
+			lines = append(lines, "# HELP "+name+" "+h)
+		}
+		lines = append(lines, "# TYPE "+name+" "+types[name])
+		lines = append(lines, samples[name]...)
+	}
+	return lines
+}
+
+func sanitizePrometheusName(name string) string {
+	return invalidPrometheusChars.ReplaceAllString(strings.ToLower(name), "_")
+}
+
+func prometheusLabel(key, value string) string {
+	value = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+	return fmt.Sprintf(`%s="%s"`, sanitizePrometheusName(key), value)
+}
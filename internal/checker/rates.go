@@ -0,0 +1,66 @@
+package checker
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// Rates remembers the counters of the previous scrape, so that per-second
+// rates can be derived from them. It is safe for concurrent use.
+type Rates struct {
+	mu   sync.Mutex
+	prev map[string]counterSample
+}
+
+type counterSample struct {
+	value float64
+	at    time.Time
+}
+
+// NewRates returns an empty Rates.
+func NewRates() *Rates {
+	return &Rates{prev: map[string]counterSample{}}
+}
+
+// rateFields returns a `<field>_rate` field for each counter of m whose
+// previous value is known. Counter resets yield no rate for the interval.
+func (r *Rates) rateFields(m metric, at time.Time) []field {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	fields := []field{}
+	for _, f := range m.fields {
+		if !strings.HasSuffix(f.key, "Count") {
+			continue
+		}
+		value, ok := toFloat(f.value)
+		if !ok {
+			continue
+		}
+
+		key := m.seriesKey() + " " + f.key
+		prev, seen := r.prev[key]
+		r.prev[key] = counterSample{value, at}
+		if !seen || value < prev.value {
+			continue
+		}
+		elapsed := at.Sub(prev.at).Seconds()
+		if elapsed <= 0 {
+			continue
+		}
+		fields = append(fields, field{f.key + "_rate", (value - prev.value) / elapsed})
+	}
+	return fields
+}
+
+// toFloat converts numeric field values into a float64.
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case int64:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	return 0, false
+}
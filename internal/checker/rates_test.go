@@ -0,0 +1,82 @@
+package checker
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRateFields(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	type scrape struct {
+		fields []field
+		after  time.Duration
+		want   []field
+	}
+	tests := []struct {
+		name    string
+		scrapes []scrape
+	}{
+		{"first scrape", []scrape{
+			{[]field{{"Count", int64(10)}}, 0, []field{}},
+		}},
+		{"increase", []scrape{
+			{[]field{{"Count", int64(10)}}, 0, []field{}},
+			{[]field{{"Count", int64(15)}}, 10 * time.Second, []field{{"Count_rate", 0.5}}},
+			{[]field{{"Count", int64(35)}}, 20 * time.Second, []field{{"Count_rate", 2.0}}},
+		}},
+		{"counter reset", []scrape{
+			{[]field{{"Count", int64(10)}}, 0, []field{}},
+			{[]field{{"Count", int64(4)}}, 10 * time.Second, []field{}},
+			{[]field{{"Count", int64(9)}}, 20 * time.Second, []field{{"Count_rate", 0.5}}},
+		}},
+		{"zero elapsed time", []scrape{
+			{[]field{{"Count", int64(10)}}, 0, []field{}},
+			{[]field{{"Count", int64(15)}}, 0, []field{}},
+		}},
+		{"float counter", []scrape{
+			{[]field{{"Count", 1.5}}, 0, []field{}},
+			{[]field{{"Count", 6.5}}, 10 * time.Second, []field{{"Count_rate", 0.5}}},
+		}},
+		{"not a counter", []scrape{
+			{[]field{{"Mean", 1.5}, {"Count", "n/a"}}, 0, []field{}},
+			{[]field{{"Mean", 2.5}, {"Count", "n/a"}}, 10 * time.Second, []field{}},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewRates()
+			for i, s := range tt.scrapes {
+				m := metric{mbeanType: "ColumnFamily", tags: []tag{{"cf", "users"}}, fields: s.fields}
+				if got := r.rateFields(m, start.Add(s.after)); !reflect.DeepEqual(got, s.want) {
+					t.Errorf("scrape %d: rateFields() = %v, want %v", i, got, s.want)
+				}
+			}
+		})
+	}
+}
+
+func TestRateFieldsPerSeries(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	r := NewRates()
+	users := metric{mbeanType: "ColumnFamily", tags: []tag{{"cf", "users"}}, fields: []field{{"Count", int64(10)}}}
+	events := metric{mbeanType: "ColumnFamily", tags: []tag{{"cf", "events"}}, fields: []field{{"Count", int64(100)}}}
+	r.rateFields(users, start)
+	if got := r.rateFields(events, start.Add(10*time.Second)); len(got) != 0 {
+		t.Errorf("rateFields() of another series = %v, want none", got)
+	}
+}
+
+func TestRenderRates(t *testing.T) {
+	cfg := Config{Measurement: "ckc", Hostname: "node1", Rates: NewRates()}
+	want := []string{
+		"Count=10i,Mean=1.500000",
+		"Count=30i,Count_rate=2.000000,Mean=1.500000",
+		"Count=5i,Mean=1.500000",
+		"Count=10i,Count_rate=0.500000,Mean=1.500000",
+	}
+	if got := renderScrapes(t, cfg, 10, 30, 5, 10); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("fields = %q, want %q", got, want)
+	}
+}
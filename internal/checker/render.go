@@ -0,0 +1,759 @@
+package checker
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// metric is a single MBean read, with its tags and fields already extracted.
+type metric struct {
+	// source is the index of the response the metric comes from.
+	source    int
+	keyPath   string
+	mbeanType string
+	name      string
+	tags      []tag
+	fields    []field
+	// histograms holds the raw buckets of histogram attributes, for the
+	// prometheus histograms.
+	histograms []histogram
+	timestamp  time.Time
+}
+
+// scopeTags names the tag holding the scope segment, per MBean type.
+var scopeTags = map[string]string{
+	"ColumnFamily": "cf",
+	"Table":        "cf",
+	"ThreadPools":  "pool",
+	"Cache":        "cache",
+}
+
+func (m metric) hasTag(key string) bool {
+	for _, t := range m.tags {
+		if t.key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// seriesKey identifies the series of m across scrapes.
+func (m metric) seriesKey() string {
+	parts := []string{m.mbeanType}
+	for _, t := range m.tags {
+		parts = append(parts, t.key+"="+t.value)
+	}
+	return strings.Join(parts, ",")
+}
+
+type tag struct {
+	key, value string
+}
+
+// staticTags returns the tags added to every line, the host unless disabled
+// followed by the configured ones sorted by key.
+func staticTags(cfg Config) []tag {
+	tags := []tag{}
+	if !cfg.NoHostTag {
+		key := cfg.HostTagKey
+		if key == "" {
+			key = "host"
+		}
+		tags = append(tags, tag{key, cfg.Hostname})
+	}
+	return append(tags, mapTags(cfg.Tags)...)
+}
+
+// mapTags converts a map into tags sorted by key.
+func mapTags(tags map[string]string) []tag {
+	list := make([]tag, 0, len(tags))
+	for key, value := range tags {
+		list = append(list, tag{key, value})
+	}
+	return sortTags(list)
+}
+
+// sortTags sorts tags by key in place, as the line protocol recommends,
+// and returns them.
+func sortTags(tags []tag) []tag {
+	sort.SliceStable(tags, func(i, j int) bool { return tags[i].key < tags[j].key })
+	return tags
+}
+
+type field struct {
+	key   string
+	value interface{}
+}
+
+// Render converts a jolokia response into output lines in the configured
+// format.
+func Render(resp *Response, cfg Config) ([]string, error) {
+	lines, _, err := RenderWithStats(resp, cfg)
+	return lines, err
+}
+
+// RenderWithStats works as Render, also reporting what happened to the
+// entries of the response.
+func RenderWithStats(resp *Response, cfg Config) ([]string, Stats, error) {
+	lines, stats, err := RenderAll([]*Response{resp}, cfg)
+	return lines, stats[0], err
+}
+
+// RenderAll renders the responses of several jolokia agents together, so
+// formats grouping the series by name stay valid. Stats are reported per
+// response.
+func RenderAll(resps []*Response, cfg Config) ([]string, []Stats, error) {
+	metrics, stats, err := collectAll(resps, cfg)
+	if err != nil {
+		return nil, stats, err
+	}
+	lines, err := formatLines(metrics, cfg)
+	return lines, stats, err
+}
+
+// collectAll collects the metrics of all the responses, once, so they can be
+// rendered in several formats without counting the rates and deltas twice.
+func collectAll(resps []*Response, cfg Config) ([]metric, []Stats, error) {
+	stats := make([]Stats, len(resps))
+	metrics := []metric{}
+	for i, resp := range resps {
+		collected, err := collect(resp, cfg, &stats[i])
+		if err != nil {
+			return nil, stats, err
+		}
+		for j := range collected {
+			collected[j].source = i
+		}
+		metrics = append(metrics, collected...)
+	}
+	metrics = mergeDuplicates(metrics, cfg)
+	if cfg.Activity != nil {
+		metrics = cfg.Activity.dropInactive(metrics, stats)
+	}
+	if cfg.RollupKeyspace {
+		metrics = rollupKeyspaces(metrics, cfg)
+	}
+	// The rollups sum every table, changed or not, so unchanged series are
+	// only left out of what is emitted. They are left out before the cap,
+	// and only the series left after it are recorded as emitted.
+	now := time.Now()
+	if cfg.Changes != nil {
+		metrics = cfg.Changes.dropUnchanged(metrics, stats, now)
+	}
+	if cfg.MaxSeries > 0 && len(metrics) > cfg.MaxSeries {
+		slog.Warn("Too many series, dropping those above --max-series", "series", len(metrics), "max_series", cfg.MaxSeries)
+		for _, m := range metrics[cfg.MaxSeries:] {
+			stats[m.source].SkippedByMaxSeries++
+			stats[m.source].Emitted--
+		}
+		metrics = metrics[:cfg.MaxSeries]
+	}
+	if cfg.Changes != nil {
+		cfg.Changes.record(metrics, now)
+	}
+	return metrics, stats, nil
+}
+
+// formatLines renders the metrics in cfg.OutputFormat.
+func formatLines(metrics []metric, cfg Config) ([]string, error) {
+	switch cfg.OutputFormat {
+	case "prometheus":
+		return prometheusLines(metrics, cfg), nil
+	case "table":
+		return tableLines(metrics, cfg), nil
+	case "graphite":
+		return renderChunks(metrics, cfg, graphiteLines), nil
+	case "json":
+		return renderChunks(metrics, cfg, jsonLines), nil
+	case "opentsdb":
+		return renderChunks(metrics, cfg, openTSDBLines), nil
+	case "statsd":
+		return renderChunks(metrics, cfg, statsdLines), nil
+	case "influx", "":
+		return renderChunks(metrics, cfg, influxLines), nil
+	}
+	return nil, fmt.Errorf("unknown output format %q", cfg.OutputFormat)
+}
+
+// renderChunks renders the metrics with cfg.Workers goroutines, for the
+// formats with lines independent from each other, keeping their order.
+func renderChunks(metrics []metric, cfg Config, render func([]metric, Config) []string) []string {
+	chunks := chunkSize(len(metrics), cfg.Workers)
+	if chunks >= len(metrics) {
+		return render(metrics, cfg)
+	}
+
+	results := make([][]string, (len(metrics)+chunks-1)/chunks)
+	var wg sync.WaitGroup
+	for i := range results {
+		end := (i + 1) * chunks
+		if end > len(metrics) {
+			end = len(metrics)
+		}
+		wg.Add(1)
+		go func(i int, chunk []metric) {
+			defer wg.Done()
+			results[i] = render(chunk, cfg)
+		}(i, metrics[i*chunks:end])
+	}
+	wg.Wait()
+
+	lines := []string{}
+	for _, chunk := range results {
+		lines = append(lines, chunk...)
+	}
+	return lines
+}
+
+// mergeDuplicates merges the metrics whose tags collapse to the same series,
+// which would otherwise overwrite each other in the database. Fields of the
+// first metric win over those of the following ones.
+func mergeDuplicates(metrics []metric, cfg Config) []metric {
+	index := map[string]int{}
+	merged := make([]metric, 0, len(metrics))
+	for _, m := range metrics {
+		// Tags are sorted as the output formats do, so a different order of
+		// the segments still collides.
+		key := metric{mbeanType: m.mbeanType, tags: sortTags(append([]tag{}, m.tags...))}.seriesKey()
+		i, ok := index[key]
+		if !ok {
+			index[key] = len(merged)
+			merged = append(merged, m)
+			continue
+		}
+
+		first := &merged[i]
+		if cfg.WarnDuplicates {
+			slog.Warn("MBeans map to the same series, merging them", "series", key,
+				"key_path", first.keyPath, "duplicate_key_path", m.keyPath)
+		}
+		fields := append([]field{}, first.fields...)
+		for _, f := range m.fields {
+			if !hasField(fields, f.key) {
+				fields = append(fields, f)
+			}
+		}
+		sort.SliceStable(fields, func(i, j int) bool { return fields[i].key < fields[j].key })
+		first.fields = fields
+		histograms := append([]histogram{}, first.histograms...)
+		for _, h := range m.histograms {
+			if !hasHistogram(histograms, h.key) {
+				histograms = append(histograms, h)
+			}
+		}
+		first.histograms = histograms
+	}
+	return merged
+}
+
+func hasField(fields []field, key string) bool {
+	for _, f := range fields {
+		if f.key == key {
+			return true
+		}
+	}
+	return false
+}
+
+func hasHistogram(histograms []histogram, key string) bool {
+	for _, h := range histograms {
+		if h.key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// Stats counts what happened to the entries of a response while rendering.
+type Stats struct {
+	Fetched           int
+	SkippedByMetric   int
+	SkippedByKeyspace int
+	SkippedByTable    int
+	SkippedBySampling int
+	SkippedByZeros    int
+	SkippedByMinValue int
+	SkippedUnchanged  int
+	// SkippedInactive counts the series of tables left out by --active-only.
+	SkippedInactive int
+	// SkippedByMaxSeries counts the series dropped by --max-series.
+	SkippedByMaxSeries int
+	Emitted            int
+}
+
+func (s *Stats) add(other Stats) {
+	s.Fetched += other.Fetched
+	s.SkippedByMetric += other.SkippedByMetric
+	s.SkippedByKeyspace += other.SkippedByKeyspace
+	s.SkippedByTable += other.SkippedByTable
+	s.SkippedBySampling += other.SkippedBySampling
+	s.SkippedByZeros += other.SkippedByZeros
+	s.SkippedByMinValue += other.SkippedByMinValue
+	s.SkippedUnchanged += other.SkippedUnchanged
+	s.SkippedInactive += other.SkippedInactive
+	s.SkippedByMaxSeries += other.SkippedByMaxSeries
+	s.Emitted += other.Emitted
+}
+
+// Summarize applies the filters to resp without rendering anything, and
+// reports what would be emitted.
+func Summarize(resp *Response, cfg Config) Stats {
+	stats := Stats{}
+	collect(resp, cfg, &stats)
+	return stats
+}
+
+// collect extracts the metrics of resp. Only with cfg.Strict can it fail, on
+// the first value it cannot classify.
+func collect(resp *Response, cfg Config, stats *Stats) ([]metric, error) {
+	// Map iteration order is random, so keys are sorted for the output to
+	// be stable.
+	keyPaths := make([]string, 0, len(resp.Value))
+	for keyPath := range resp.Value {
+		keyPaths = append(keyPaths, keyPath)
+	}
+	sort.Strings(keyPaths)
+	timestamp := responseTimestamp(resp, cfg)
+
+	chunks := chunkSize(len(keyPaths), cfg.Workers)
+	if chunks >= len(keyPaths) {
+		return collectKeys(resp, keyPaths, timestamp, cfg, stats)
+	}
+
+	// Large responses are split in chunks collected concurrently, then
+	// joined back in order.
+	results := make([][]metric, (len(keyPaths)+chunks-1)/chunks)
+	chunkStats := make([]Stats, len(results))
+	errs := make([]error, len(results))
+	var wg sync.WaitGroup
+	for i := range results {
+		end := (i + 1) * chunks
+		if end > len(keyPaths) {
+			end = len(keyPaths)
+		}
+		wg.Add(1)
+		go func(i int, keys []string) {
+			defer wg.Done()
+			results[i], errs[i] = collectKeys(resp, keys, timestamp, cfg, &chunkStats[i])
+		}(i, keyPaths[i*chunks:end])
+	}
+	wg.Wait()
+
+	metrics := []metric{}
+	for i := range results {
+		if errs[i] != nil {
+			return nil, errs[i]
+		}
+		metrics = append(metrics, results[i]...)
+		stats.add(chunkStats[i])
+	}
+	return metrics, nil
+}
+
+// strictError reports a value --strict refuses to skip.
+func strictError(keyPath, field, problem string) error {
+	if field != "" {
+		return &ResponseError{fmt.Errorf("%s in field %s of %s", problem, field, keyPath)}
+	}
+	return &ResponseError{fmt.Errorf("%s in %s", problem, keyPath)}
+}
+
+// responseTimestamp returns the timestamp of the lines rendered from resp,
+// the one reported by jolokia unless --timestamp-source overrides it.
+func responseTimestamp(resp *Response, cfg Config) time.Time {
+	if !cfg.FixedTimestamp.IsZero() {
+		return cfg.FixedTimestamp
+	}
+	if cfg.TimestampSource == "local" {
+		return time.Now()
+	}
+	return time.Unix(resp.TimeStamp, 0)
+}
+
+// chunkSize splits n items between the given number of workers.
+func chunkSize(n, workers int) int {
+	if workers <= 1 || n == 0 {
+		return n
+	}
+	return (n + workers - 1) / workers
+}
+
+// collectKeys extracts the metrics of the given keys of resp, applying the
+// filters.
+func collectKeys(resp *Response, keyPaths []string, timestamp time.Time, cfg Config, stats *Stats) ([]metric, error) {
+	metrics := []metric{}
+	for _, keyPath := range keyPaths {
+		valueMap := resp.Value[keyPath]
+		stats.Fetched++
+		mbean := keyPath
+		keyPath = strings.Replace(keyPath, "org.apache.cassandra.metrics:", "", 1)
+		if skipMetric(keyPath, cfg) {
+			stats.SkippedByMetric++
+			continue
+		}
+		if skipKeyspace(keyPath, cfg) {
+			stats.SkippedByKeyspace++
+			continue
+		}
+		if skipTable(keyPath, cfg) {
+			stats.SkippedByTable++
+			continue
+		}
+		if skipSample(keyPath, cfg) {
+			stats.SkippedBySampling++
+			continue
+		}
+
+		m := metric{keyPath: keyPath, mbeanType: segment(keyPath, "type"), timestamp: timestamp}
+		if m.mbeanType == "" {
+			m.mbeanType = DefaultMBeanType
+		}
+		if resp.Node != "" {
+			m.tags = append(m.tags, tag{"node", resp.Node})
+		}
+		m.tags = append(m.tags, mapTags(resp.Tags)...)
+		keyParts := splitKeyPath(keyPath)
+		for _, part := range keyParts {
+			kv := strings.SplitN(part, "=", 2)
+			if len(kv) < 2 {
+				if cfg.Strict {
+					return nil, strictError(keyPath, "", fmt.Sprintf("malformed segment %q", part))
+				}
+				slog.Debug("Ignoring malformed segment", "key_path", keyPath, "segment", part)
+				continue
+			}
+			if kv[1] == "" && kv[0] != "name" && cfg.DropEmptyTags {
+				slog.Debug("Ignoring empty segment", "key_path", keyPath, "segment", part)
+				continue
+			}
+			switch kv[0] {
+			case "type":
+			case "name":
+				m.name = kv[1]
+				m.tags = append(m.tags, tag{"metric", stripMetricPrefix(kv[1], cfg)})
+			case "scope":
+				scopeTag, ok := scopeTags[m.mbeanType]
+				if !ok {
+					scopeTag = "scope"
+				}
+				m.tags = append(m.tags, tag{scopeTag, kv[1]})
+			default:
+				m.tags = append(m.tags, tag{kv[0], kv[1]})
+			}
+		}
+		if !onlyDefaultMBeanType(cfg) {
+			m.tags = append(m.tags, tag{"type", m.mbeanType})
+		}
+		if cfg.EmitMBeanTag {
+			m.tags = append(m.tags, tag{"mbean", mbean})
+		}
+		if cfg.DefaultTags && scopeTags[m.mbeanType] == "cf" {
+			if !m.hasTag("keyspace") {
+				m.tags = append([]tag{{"keyspace", cfg.DefaultKeyspace}}, m.tags...)
+			}
+			if !m.hasTag("cf") {
+				m.tags = append(m.tags, tag{"cf", cfg.DefaultCF})
+			}
+		}
+
+		if cfg.FlattenComposite {
+			valueMap = flattenValues(valueMap)
+		}
+		valueKeys := make([]string, 0, len(valueMap))
+		for valueKey := range valueMap {
+			valueKeys = append(valueKeys, valueKey)
+		}
+		sort.Strings(valueKeys)
+
+		zeroValuesCount := 0
+		numericValues := 0
+		maxAbsValue := 0.0
+		observe := func(value interface{}) {
+			numericValues++
+			if reflect.ValueOf(value).IsZero() {
+				zeroValuesCount++
+			}
+			if f, ok := toFloat(value); ok && math.Abs(f) > maxAbsValue {
+				maxAbsValue = math.Abs(f)
+			}
+		}
+		for _, valueKey := range valueKeys {
+			value := valueMap[valueKey]
+			if value == nil || skipField(valueKey, cfg) {
+				continue
+			}
+			if newKey, ok := cfg.Rename[valueKey]; ok {
+				valueKey = newKey
+			}
+			rt := reflect.TypeOf(value)
+			if rt.Kind() == reflect.Slice {
+				if !cfg.Histograms && !cfg.PrometheusHistograms {
+					continue
+				}
+				values, _ := value.([]interface{})
+				buckets, err := parseBuckets(valueKey, values)
+				if err != nil {
+					if cfg.Strict {
+						return nil, strictError(keyPath, valueKey, err.Error())
+					}
+					slog.Debug("Ignoring histogram", "key_path", keyPath, "error", err)
+					continue
+				}
+				if cfg.PrometheusHistograms {
+					m.histograms = append(m.histograms, histogram{valueKey, buckets})
+				}
+				if !cfg.Histograms {
+					continue
+				}
+				for _, f := range histogramFields(valueKey, buckets) {
+					f.value = scaleValue(f.value, f.key, cfg)
+					m.fields = append(m.fields, f)
+					observe(f.value)
+				}
+				continue
+			}
+			if n, ok := value.(json.Number); ok {
+				value = parseNumber(n)
+			}
+			if s, ok := value.(string); ok && contains(cfg.NumericFields, valueKey) {
+				if _, err := strconv.ParseFloat(s, 64); err == nil {
+					value = parseNumber(json.Number(s))
+				} else {
+					slog.Debug("Keeping non-numeric value as a string", "key_path", keyPath, "field", valueKey, "value", s)
+				}
+			}
+			if f, ok := nonFinite(value); ok {
+				if !cfg.KeepNaN && cfg.Strict {
+					return nil, strictError(keyPath, valueKey, fmt.Sprintf("non-finite value %v", f))
+				}
+				if !cfg.KeepNaN {
+					slog.Debug("Ignoring non-finite value", "key_path", keyPath, "field", valueKey, "value", f)
+					continue
+				}
+			}
+			value = scaleValue(value, valueKey, cfg)
+			switch v := value.(type) {
+			case int64, int32, int16, int8, int, uint64, uint32, uint16, uint8, uint:
+				m.fields = append(m.fields, field{valueKey, v})
+				observe(v)
+			case float32, float64, complex64, complex128:
+				m.fields = append(m.fields, field{valueKey, v})
+				observe(v)
+			case string:
+				m.fields = append(m.fields, field{valueKey, v})
+			case bool:
+				if !cfg.BoolAsInt {
+					m.fields = append(m.fields, field{valueKey, v})
+					break
+				}
+				n := int64(0)
+				if v {
+					n = 1
+				}
+				m.fields = append(m.fields, field{valueKey, n})
+				observe(n)
+			default:
+				if cfg.Strict {
+					return nil, strictError(keyPath, valueKey, fmt.Sprintf("unexpected value of type %T", v))
+				}
+				slog.Debug("Ignoring value of unexpected type", "key_path", keyPath, "field", valueKey, "type", fmt.Sprintf("%T", v))
+			}
+		}
+
+		if cfg.Rates != nil {
+			m.fields = append(m.fields, cfg.Rates.rateFields(m, m.timestamp)...)
+		}
+		if cfg.Deltas != nil {
+			m.fields = append(m.fields, cfg.Deltas.deltaFields(m)...)
+		}
+		// Renamed, histogram, rate and delta fields may be out of order.
+		sort.SliceStable(m.fields, func(i, j int) bool { return m.fields[i].key < m.fields[j].key })
+
+		if cfg.SkipZeros && (zeroValuesCount == numericValues) {
+			slog.Debug("Skipping metric with only zeros", "key_path", keyPath,
+				"zero_values", zeroValuesCount, "numeric_values", numericValues)
+			stats.SkippedByZeros++
+			continue
+		}
+
+		if numericValues > 0 && maxAbsValue < cfg.MinValue {
+			slog.Debug("Skipping metric below the minimum value", "key_path", keyPath,
+				"min_value", cfg.MinValue, "max_value", maxAbsValue)
+			stats.SkippedByMinValue++
+			continue
+		}
+
+		if len(m.fields) > 0 || len(m.histograms) > 0 {
+			stats.Emitted++
+			metrics = append(metrics, m)
+		}
+	}
+	return metrics, nil
+}
+
+// parseNumber converts a JSON number into an int64 when it has no fraction
+// nor exponent and fits, and into a float64 otherwise.
+func parseNumber(n json.Number) interface{} {
+	if !strings.ContainsAny(n.String(), ".eE") {
+		if i, err := n.Int64(); err == nil {
+			return i
+		}
+	}
+	f, _ := n.Float64()
+	return f
+}
+
+// scaleValue multiplies a numeric value by the factor configured for its
+// field, turning it into a float.
+func scaleValue(value interface{}, key string, cfg Config) interface{} {
+	factor, ok := cfg.Scale[key]
+	if !ok {
+		return value
+	}
+	if f, ok := toFloat(value); ok {
+		return f * factor
+	}
+	return value
+}
+
+// nonFinite tells whether value, decoded as a number, is NaN or infinite,
+// such as a number beyond the float64 range or a --numeric-fields "NaN".
+// Strings are left alone, as they are attributes of their own.
+func nonFinite(value interface{}) (float64, bool) {
+	f, ok := value.(float64)
+	return f, ok && (math.IsNaN(f) || math.IsInf(f, 0))
+}
+
+// numberString formats numeric field values in their shortest form; other
+// values are reported as not ok.
+func numberString(value interface{}) (string, bool) {
+	switch v := value.(type) {
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64), true
+	case float32:
+		return strconv.FormatFloat(float64(v), 'g', -1, 32), true
+	case int64, int32, int16, int8, int, uint64, uint32, uint16, uint8, uint:
+		return fmt.Sprintf("%d", v), true
+	}
+	return "", false
+}
+
+// MetricNames returns the distinct metric names found in the responses,
+// sorted and grouped by MBean type, regardless of the filters.
+func MetricNames(resps []*Response) map[string][]string {
+	seen := map[string]map[string]bool{}
+	for _, resp := range resps {
+		for keyPath := range resp.Value {
+			keyPath = strings.Replace(keyPath, "org.apache.cassandra.metrics:", "", 1)
+			name := segment(keyPath, "name")
+			if name == "" {
+				continue
+			}
+			mbeanType := segment(keyPath, "type")
+			if mbeanType == "" {
+				mbeanType = DefaultMBeanType
+			}
+			if seen[mbeanType] == nil {
+				seen[mbeanType] = map[string]bool{}
+			}
+			seen[mbeanType][name] = true
+		}
+	}
+
+	names := map[string][]string{}
+	for mbeanType, set := range seen {
+		for name := range set {
+			names[mbeanType] = append(names[mbeanType], name)
+		}
+		sort.Strings(names[mbeanType])
+	}
+	return names
+}
+
+// UnknownNames returns the --include and --skip entries matching no metric
+// name of the responses, which are likely typos. --skip is ignored along
+// with --include, so only checked without it. Bulk reads only return what
+// the filters select, so nothing is checked for them.
+func UnknownNames(resps []*Response, cfg Config) []string {
+	unknown := []string{}
+	if bulk(cfg) {
+		return unknown
+	}
+
+	present := map[string]bool{}
+	for _, names := range MetricNames(resps) {
+		for _, name := range names {
+			present[name] = true
+		}
+	}
+
+	names := cfg.Include
+	if len(names) == 0 {
+		names = cfg.Skip
+	}
+	seen := map[string]bool{}
+	for _, name := range names {
+		if !present[name] && !seen[name] {
+			seen[name] = true
+			unknown = append(unknown, name)
+		}
+	}
+	sort.Strings(unknown)
+	return unknown
+}
+
+// FieldValue is the value of a field in one series.
+type FieldValue struct {
+	// Series names the series, as the dot separated values of its tags
+	// followed by the metric name.
+	Series string
+	Value  float64
+}
+
+// FieldValues applies the filters to the responses and returns the numeric
+// values of the given field, sorted by series.
+func FieldValues(resps []*Response, cfg Config, key string) []FieldValue {
+	values := []FieldValue{}
+	for _, resp := range resps {
+		metrics, _ := collect(resp, cfg, &Stats{})
+		for _, m := range metrics {
+			for _, f := range m.fields {
+				if f.key != key {
+					continue
+				}
+				v, ok := toFloat(f.value)
+				if !ok {
+					continue
+				}
+				parts := []string{}
+				for _, t := range m.tags {
+					if t.key != "metric" {
+						parts = append(parts, t.value)
+					}
+				}
+				parts = append(parts, m.name)
+				values = append(values, FieldValue{Series: strings.Join(parts, "."), Value: v})
+			}
+		}
+	}
+	sort.Slice(values, func(i, j int) bool { return values[i].Series < values[j].Series })
+	return values
+}
+
+// stripMetricPrefix removes --strip-metric-prefix from the metric tag. Names
+// without the prefix, or made only of it, are kept as they are.
+func stripMetricPrefix(name string, cfg Config) string {
+	stripped, ok := strings.CutPrefix(name, cfg.StripMetricPrefix)
+	if !ok || stripped == "" {
+		return name
+	}
+	return stripped
+}
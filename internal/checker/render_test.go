@@ -0,0 +1,689 @@
+package checker
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// renderValue renders a response with the given jolokia value, decoded as
+// Fetch does.
+func renderValue(t *testing.T, value string, cfg Config) ([]string, error) {
+	t.Helper()
+	resp := &Response{}
+	if err := json.Unmarshal([]byte(`{"status": 200, "timestamp": 1700000000, "value": `+value+`}`), resp); err != nil {
+		t.Fatal(err)
+	}
+	return Render(resp, cfg)
+}
+
+func TestRenderMalformedSegment(t *testing.T) {
+	value := `{"org.apache.cassandra.metrics:keyspace=app,bogus,name=ReadLatency,scope=users,type=ColumnFamily": {"Count": 3}}`
+	tests := []struct {
+		name    string
+		cfg     Config
+		want    []string
+		wantErr bool
+	}{
+		{"ignored", Config{Measurement: "ckc", Hostname: "node1"}, []string{"ckc,cf=users,host=node1,keyspace=app,metric=ReadLatency Count=3i 1700000000000000000"}, false},
+		{"strict", Config{Measurement: "ckc", Hostname: "node1", Strict: true}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lines, err := renderValue(t, value, tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Render() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if strings.Join(lines, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("Render() = %q, want %q", lines, tt.want)
+			}
+		})
+	}
+}
+
+func TestSegment(t *testing.T) {
+	keyPath := "keyspace=app,bogus,name=ReadLatency,scope=users,type=ColumnFamily"
+	tests := []struct {
+		key, want string
+	}{
+		{"keyspace", "app"},
+		{"scope", "users"},
+		{"bogus", ""},
+		{"missing", ""},
+	}
+	for _, tt := range tests {
+		if got := segment(keyPath, tt.key); got != tt.want {
+			t.Errorf("segment(%q) = %q, want %q", tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestRenderDefaultTags(t *testing.T) {
+	value := `{
+		"org.apache.cassandra.metrics:keyspace=app,name=LiveSSTableCount,type=ColumnFamily": {"Value": 4},
+		"org.apache.cassandra.metrics:name=TotalDiskSpaceUsed,type=ColumnFamily": {"Value": 123}
+	}`
+	tests := []struct {
+		name string
+		cfg  Config
+		want []string
+	}{
+		{"off", Config{Measurement: "ckc", Hostname: "node1"}, []string{
+			"ckc,host=node1,keyspace=app,metric=LiveSSTableCount Value=4i 1700000000000000000",
+			"ckc,host=node1,metric=TotalDiskSpaceUsed Value=123i 1700000000000000000",
+		}},
+		{"placeholders", Config{Measurement: "ckc", Hostname: "node1", DefaultTags: true, DefaultKeyspace: "_global", DefaultCF: "_none"}, []string{
+			"ckc,cf=_none,host=node1,keyspace=app,metric=LiveSSTableCount Value=4i 1700000000000000000",
+			"ckc,cf=_none,host=node1,keyspace=_global,metric=TotalDiskSpaceUsed Value=123i 1700000000000000000",
+		}},
+		{"custom placeholders", Config{Measurement: "ckc", Hostname: "node1", DefaultTags: true, DefaultKeyspace: "all", DefaultCF: "all"}, []string{
+			"ckc,cf=all,host=node1,keyspace=app,metric=LiveSSTableCount Value=4i 1700000000000000000",
+			"ckc,cf=all,host=node1,keyspace=all,metric=TotalDiskSpaceUsed Value=123i 1700000000000000000",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lines, err := renderValue(t, value, tt.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Join(lines, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("Render() = %q, want %q", lines, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseNumber(t *testing.T) {
+	tests := []struct {
+		in   string
+		want interface{}
+	}{
+		{"9007199254740993", int64(9007199254740993)},
+		{"-42", int64(-42)},
+		{"1.5", 1.5},
+		{"1e3", 1000.0},
+		{"18446744073709551616", 18446744073709551616.0},
+	}
+	for _, tt := range tests {
+		if got := parseNumber(json.Number(tt.in)); got != tt.want {
+			t.Errorf("parseNumber(%s) = %#v, want %#v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestRenderLargeInteger(t *testing.T) {
+	value := `{"org.apache.cassandra.metrics:keyspace=app,name=BytesFlushed,scope=users,type=ColumnFamily": {"Count": 9007199254740993, "Mean": 2.5}}`
+	lines, err := renderValue(t, value, Config{Measurement: "ckc", Hostname: "node1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "ckc,cf=users,host=node1,keyspace=app,metric=BytesFlushed Count=9007199254740993i,Mean=2.500000 1700000000000000000"
+	if len(lines) != 1 || lines[0] != want {
+		t.Errorf("Render() = %q, want %q", lines, want)
+	}
+}
+
+func TestRenderRename(t *testing.T) {
+	value := `{
+		"org.apache.cassandra.metrics:keyspace=app,name=ReadLatency,scope=users,type=ColumnFamily": {"99thPercentile": 12.5, "Count": 3, "OneMinuteRate": 0.5},
+		"org.apache.cassandra.metrics:keyspace=app,name=WriteLatency,scope=users,type=ColumnFamily": {"99thPercentile": 7.5, "Count": 2}
+	}`
+	cfg := Config{Measurement: "ckc", Hostname: "node1", Rename: map[string]string{"99thPercentile": "p99", "OneMinuteRate": "rate_1m"}}
+	lines, err := renderValue(t, value, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"ckc,cf=users,host=node1,keyspace=app,metric=ReadLatency Count=3i,p99=12.500000,rate_1m=0.500000 1700000000000000000",
+		"ckc,cf=users,host=node1,keyspace=app,metric=WriteLatency Count=2i,p99=7.500000 1700000000000000000",
+	}
+	if strings.Join(lines, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Render() = %q, want %q", lines, want)
+	}
+	for _, line := range lines {
+		if strings.Contains(line, "99thPercentile") || strings.Contains(line, "OneMinuteRate") {
+			t.Errorf("Render() kept an original field name in %q", line)
+		}
+	}
+}
+
+func TestRenderMBeanTypes(t *testing.T) {
+	value := `{
+		"org.apache.cassandra.metrics:name=ActiveTasks,path=request,scope=ReadStage,type=ThreadPools": {"Value": 2},
+		"org.apache.cassandra.metrics:name=HitRate,scope=KeyCache,type=Cache": {"Value": 0.5},
+		"org.apache.cassandra.metrics:keyspace=app,name=ReadLatency,scope=users,type=ColumnFamily": {"Count": 3}
+	}`
+	cfg := Config{Measurement: "ckc", Hostname: "node1", MBeanTypes: []string{"ColumnFamily", "ThreadPools", "Cache"}}
+	lines, err := renderValue(t, value, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"ckc,cf=users,host=node1,keyspace=app,metric=ReadLatency,type=ColumnFamily Count=3i 1700000000000000000",
+		"ckc,host=node1,metric=ActiveTasks,path=request,pool=ReadStage,type=ThreadPools Value=2i 1700000000000000000",
+		"ckc,cache=KeyCache,host=node1,metric=HitRate,type=Cache Value=0.500000 1700000000000000000",
+	}
+	if strings.Join(lines, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Render() = %q, want %q", lines, want)
+	}
+}
+
+func TestRenderMinValue(t *testing.T) {
+	tests := []struct {
+		name   string
+		fields string
+		cfg    Config
+		kept   bool
+	}{
+		{"below", `{"Count": 4, "Mean": 0.5}`, Config{MinValue: 5}, false},
+		{"at the threshold", `{"Count": 5, "Mean": 0.5}`, Config{MinValue: 5}, true},
+		{"above", `{"Count": 6}`, Config{MinValue: 5}, true},
+		{"negative above", `{"Value": -6}`, Config{MinValue: 5}, true},
+		{"negative below", `{"Value": -4}`, Config{MinValue: 5}, false},
+		{"no numeric field", `{"DurationUnit": "microseconds"}`, Config{MinValue: 5}, true},
+		{"zeros without skip zeros", `{"Count": 0}`, Config{MinValue: 0}, true},
+		{"zeros with skip zeros", `{"Count": 0}`, Config{SkipZeros: true}, false},
+		{"skip zeros and min value", `{"Count": 3}`, Config{SkipZeros: true, MinValue: 5}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value := `{"org.apache.cassandra.metrics:keyspace=app,name=ReadLatency,scope=users,type=ColumnFamily": ` + tt.fields + `}`
+			lines, err := renderValue(t, value, tt.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if kept := len(lines) == 1; kept != tt.kept {
+				t.Errorf("Render() = %q, want kept %v", lines, tt.kept)
+			}
+		})
+	}
+}
+
+func TestMetricNames(t *testing.T) {
+	resps := []*Response{
+		{Value: map[string]map[string]interface{}{
+			"org.apache.cassandra.metrics:keyspace=app,name=WriteLatency,scope=users,type=ColumnFamily":   {},
+			"org.apache.cassandra.metrics:keyspace=app,name=ReadLatency,scope=users,type=ColumnFamily":    {},
+			"org.apache.cassandra.metrics:keyspace=app,name=ReadLatency,scope=events,type=ColumnFamily":   {},
+			"org.apache.cassandra.metrics:name=ActiveTasks,path=request,scope=ReadStage,type=ThreadPools": {},
+		}},
+		{Value: map[string]map[string]interface{}{
+			"org.apache.cassandra.metrics:keyspace=logs,name=ReadLatency,scope=events,type=ColumnFamily":       {},
+			"org.apache.cassandra.metrics:keyspace=logs,name=LiveDiskSpaceUsed,scope=events,type=ColumnFamily": {},
+			"org.apache.cassandra.metrics:type=ColumnFamily":                                                   {},
+		}},
+	}
+	want := map[string][]string{
+		"ColumnFamily": {"LiveDiskSpaceUsed", "ReadLatency", "WriteLatency"},
+		"ThreadPools":  {"ActiveTasks"},
+	}
+	if got := MetricNames(resps); !reflect.DeepEqual(got, want) {
+		t.Errorf("MetricNames() = %v, want %v", got, want)
+	}
+}
+
+func TestRenderStaticTags(t *testing.T) {
+	value := `{
+		"org.apache.cassandra.metrics:keyspace=app,name=ReadLatency,scope=users,type=ColumnFamily": {"Count": 3},
+		"org.apache.cassandra.metrics:name=TotalDiskSpaceUsed,type=ColumnFamily": {"Value": 123}
+	}`
+	cfg := Config{Measurement: "ckc", Hostname: "cassandra-1", Tags: map[string]string{"dc": "eu1", "environment": "prod"}}
+	lines, err := renderValue(t, value, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"ckc,cf=users,dc=eu1,environment=prod,host=cassandra-1,keyspace=app,metric=ReadLatency Count=3i 1700000000000000000",
+		"ckc,dc=eu1,environment=prod,host=cassandra-1,metric=TotalDiskSpaceUsed Value=123i 1700000000000000000",
+	}
+	if strings.Join(lines, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Render() = %q, want %q", lines, want)
+	}
+}
+
+func TestRenderNonFinite(t *testing.T) {
+	value := `{"org.apache.cassandra.metrics:keyspace=app,name=ReadLatency,scope=users,type=ColumnFamily": {"Count": 3, "Mean": "NaN", "Max": 1e400, "Min": -1e400}}`
+	numeric := []string{"Mean"}
+	tests := []struct {
+		name    string
+		cfg     Config
+		want    []string
+		wantErr bool
+	}{
+		{"omitted", Config{Measurement: "ckc", Hostname: "node1", NumericFields: numeric}, []string{"ckc,cf=users,host=node1,keyspace=app,metric=ReadLatency Count=3i 1700000000000000000"}, false},
+		{"strict", Config{Measurement: "ckc", Hostname: "node1", NumericFields: numeric, Strict: true}, nil, true},
+		{"kept in prometheus", Config{OutputFormat: "prometheus", Hostname: "node1", NumericFields: numeric, KeepNaN: true}, []string{
+			"# HELP cassandra_columnfamily_readlatency_count ReadLatency Count of ColumnFamily",
+			"# TYPE cassandra_columnfamily_readlatency_count counter",
+			`cassandra_columnfamily_readlatency_count{cf="users",host="node1",keyspace="app"} 3`,
+			"# HELP cassandra_columnfamily_readlatency_max ReadLatency Max of ColumnFamily, in microseconds",
+			"# TYPE cassandra_columnfamily_readlatency_max gauge",
+			`cassandra_columnfamily_readlatency_max{cf="users",host="node1",keyspace="app"} +Inf`,
+			"# HELP cassandra_columnfamily_readlatency_mean ReadLatency Mean of ColumnFamily, in microseconds",
+			"# TYPE cassandra_columnfamily_readlatency_mean gauge",
+			`cassandra_columnfamily_readlatency_mean{cf="users",host="node1",keyspace="app"} NaN`,
+			"# HELP cassandra_columnfamily_readlatency_min ReadLatency Min of ColumnFamily, in microseconds",
+			"# TYPE cassandra_columnfamily_readlatency_min gauge",
+			`cassandra_columnfamily_readlatency_min{cf="users",host="node1",keyspace="app"} -Inf`,
+		}, false},
+		{"still left out of the line protocol", Config{Measurement: "ckc", Hostname: "node1", NumericFields: numeric, KeepNaN: true}, []string{"ckc,cf=users,host=node1,keyspace=app,metric=ReadLatency Count=3i 1700000000000000000"}, false},
+		{"string attribute kept as a string", Config{Measurement: "ckc", Hostname: "node1", KeepNaN: true}, []string{`ckc,cf=users,host=node1,keyspace=app,metric=ReadLatency Count=3i,Mean="NaN" 1700000000000000000`}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lines, err := renderValue(t, value, tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Render() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if strings.Join(lines, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("Render() = %q, want %q", lines, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderScale(t *testing.T) {
+	value := `{"org.apache.cassandra.metrics:keyspace=app,name=ReadLatency,scope=users,type=ColumnFamily": {"Count": 3, "Max": 2500, "Mean": 1500.5, "DurationUnit": "microseconds"}}`
+	cfg := Config{Measurement: "ckc", Hostname: "node1", Scale: map[string]float64{"Mean": 0.001, "Max": 0.001, "DurationUnit": 2}}
+	lines, err := renderValue(t, value, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `ckc,cf=users,host=node1,keyspace=app,metric=ReadLatency Count=3i,DurationUnit="microseconds",Max=2.500000,Mean=1.500500 1700000000000000000`
+	if len(lines) != 1 || lines[0] != want {
+		t.Errorf("Render() = %q, want %q", lines, want)
+	}
+}
+
+func TestScaleValue(t *testing.T) {
+	cfg := Config{Scale: map[string]float64{"Mean": 0.001}}
+	tests := []struct {
+		key   string
+		value interface{}
+		want  interface{}
+	}{
+		{"Mean", int64(1500), 1.5},
+		{"Mean", 2500.0, 2.5},
+		{"Count", int64(3), int64(3)},
+		{"Mean", "n/a", "n/a"},
+	}
+	for _, tt := range tests {
+		if got := scaleValue(tt.value, tt.key, cfg); got != tt.want {
+			t.Errorf("scaleValue(%v, %s) = %#v, want %#v", tt.value, tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestRenderNumericFields(t *testing.T) {
+	value := `{"org.apache.cassandra.metrics:keyspace=app,name=PendingTasks,scope=users,type=ColumnFamily": {"Value": "42", "Ratio": "0.25", "Label": "7", "State": "n/a"}}`
+	cfg := Config{Measurement: "ckc", Hostname: "node1", NumericFields: []string{"Value", "Ratio", "State"}}
+	lines, err := renderValue(t, value, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `ckc,cf=users,host=node1,keyspace=app,metric=PendingTasks Label="7",Ratio=0.250000,State="n/a",Value=42i 1700000000000000000`
+	if len(lines) != 1 || lines[0] != want {
+		t.Errorf("Render() = %q, want %q", lines, want)
+	}
+}
+
+func TestRenderDeterministic(t *testing.T) {
+	value := `{
+		"org.apache.cassandra.metrics:keyspace=app,name=ReadLatency,scope=users,type=ColumnFamily": {"Min": 0, "Max": 9, "Mean": 1.5, "Count": 3, "99thPercentile": 8.5, "50thPercentile": 1.5, "OneMinuteRate": 0.1, "DurationUnit": "microseconds"},
+		"org.apache.cassandra.metrics:keyspace=app,name=WriteLatency,scope=users,type=ColumnFamily": {"Mean": 2.5, "Count": 4, "Max": 5},
+		"org.apache.cassandra.metrics:keyspace=logs,name=ReadLatency,scope=events,type=ColumnFamily": {"Count": 5}
+	}`
+	cfg := Config{Measurement: "ckc", Hostname: "node1", Tags: map[string]string{"zone": "a", "dc": "eu1", "rack": "r1"}}
+	first, err := renderValue(t, value, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 20; i++ {
+		lines, err := renderValue(t, value, cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Join(lines, "\n") != strings.Join(first, "\n") {
+			t.Fatalf("Render() = %q, then %q", first, lines)
+		}
+	}
+
+	for _, line := range first {
+		parts := strings.Split(line, " ")
+		for _, list := range []string{parts[0][strings.Index(parts[0], ",")+1:], parts[1]} {
+			keys := []string{}
+			for _, kv := range strings.Split(list, ",") {
+				key, _, _ := strings.Cut(kv, "=")
+				keys = append(keys, key)
+			}
+			if !sort.StringsAreSorted(keys) {
+				t.Errorf("keys %q of %q are not sorted", keys, line)
+			}
+		}
+	}
+}
+
+func TestRenderHostTag(t *testing.T) {
+	value := `{"org.apache.cassandra.metrics:keyspace=app,name=ReadLatency,scope=users,type=ColumnFamily": {"Count": 3}}`
+	tests := []struct {
+		name string
+		cfg  Config
+		want string
+	}{
+		{"default", Config{Measurement: "ckc", Hostname: "node1"}, "ckc,cf=users,host=node1,keyspace=app,metric=ReadLatency Count=3i 1700000000000000000"},
+		{"renamed", Config{Measurement: "ckc", Hostname: "node1", HostTagKey: "source_host"}, "ckc,cf=users,keyspace=app,metric=ReadLatency,source_host=node1 Count=3i 1700000000000000000"},
+		{"omitted", Config{Measurement: "ckc", Hostname: "node1", NoHostTag: true}, "ckc,cf=users,keyspace=app,metric=ReadLatency Count=3i 1700000000000000000"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lines, err := renderValue(t, value, tt.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(lines) != 1 || lines[0] != tt.want {
+				t.Errorf("Render() = %q, want %q", lines, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderFields(t *testing.T) {
+	value := `{"org.apache.cassandra.metrics:keyspace=app,name=ReadLatency,scope=users,type=ColumnFamily": {"Count": 3, "Max": 9, "FiveMinuteRate": 2, "999thPercentile": 8}}`
+	tests := []struct {
+		name string
+		cfg  Config
+		want []string
+	}{
+		{"all", Config{Measurement: "ckc", Hostname: "node1"},
+			[]string{"ckc,cf=users,host=node1,keyspace=app,metric=ReadLatency 999thPercentile=8i,Count=3i,FiveMinuteRate=2i,Max=9i 1700000000000000000"}},
+		{"whitelist", Config{Measurement: "ckc", Hostname: "node1", Fields: []string{"Count", "999thPercentile"}},
+			[]string{"ckc,cf=users,host=node1,keyspace=app,metric=ReadLatency 999thPercentile=8i,Count=3i 1700000000000000000"}},
+		{"blacklist", Config{Measurement: "ckc", Hostname: "node1", SkipFields: []string{"FiveMinuteRate", "Max"}},
+			[]string{"ckc,cf=users,host=node1,keyspace=app,metric=ReadLatency 999thPercentile=8i,Count=3i 1700000000000000000"}},
+		{"nothing left", Config{Measurement: "ckc", Hostname: "node1", Fields: []string{"Mean"}}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lines, err := renderValue(t, value, tt.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Join(lines, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("Render() = %q, want %q", lines, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderTimestampSource(t *testing.T) {
+	value := `{"org.apache.cassandra.metrics:keyspace=app,name=ReadLatency,scope=users,type=ColumnFamily": {"Count": 3}}`
+	tests := []struct {
+		name string
+		cfg  Config
+		// want is the timestamp in nanoseconds, or zero for the local clock.
+		want int64
+	}{
+		{"jolokia", Config{Measurement: "ckc", Hostname: "node1", TimestampSource: "jolokia"}, 1700000000000000000},
+		{"local", Config{Measurement: "ckc", Hostname: "node1", TimestampSource: "local"}, 0},
+		{"fixed", Config{Measurement: "ckc", Hostname: "node1", FixedTimestamp: time.Date(2024, 1, 2, 3, 4, 5, 500000000, time.UTC)}, 1704164645500000000},
+		{"fixed in seconds", Config{Measurement: "ckc", Hostname: "node1", FixedTimestamp: time.Date(2024, 1, 2, 3, 4, 5, 500000000, time.UTC), TimestampPrecision: "s"}, 1704164645},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before := time.Now().UnixNano()
+			lines, err := renderValue(t, value, tt.cfg)
+			after := time.Now().UnixNano()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(lines) != 1 {
+				t.Fatalf("Render() = %q, want one line", lines)
+			}
+			got, err := strconv.ParseInt(lines[0][strings.LastIndexByte(lines[0], ' ')+1:], 10, 64)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.want == 0 && (got < before || got > after) {
+				t.Errorf("timestamp = %d, want the local clock, between %d and %d", got, before, after)
+			}
+			if tt.want != 0 && got != tt.want {
+				t.Errorf("timestamp = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnknownNames(t *testing.T) {
+	resp := &Response{}
+	value := `{"status": 200, "timestamp": 1700000000, "value": {
+		"org.apache.cassandra.metrics:keyspace=app,name=ReadLatency,scope=users,type=ColumnFamily": {"Count": 3},
+		"org.apache.cassandra.metrics:keyspace=app,name=WriteLatency,scope=users,type=ColumnFamily": {"Count": 3}
+	}}`
+	if err := json.Unmarshal([]byte(value), resp); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		cfg  Config
+		want []string
+	}{
+		{"all present", Config{Skip: []string{"ReadLatency"}, Include: []string{"WriteLatency"}}, []string{}},
+		{"typo in skip", Config{Skip: []string{"ReadLantency", "WriteLatency"}}, []string{"ReadLantency"}},
+		{"typo in include", Config{Include: []string{"ReadLatency", "WriteLatncy"}}, []string{"WriteLatncy"}},
+		{"listed twice", Config{Include: []string{"Bogus", "Absent", "Bogus"}}, []string{"Absent", "Bogus"}},
+		{"skip ignored with include", Config{Skip: []string{"ReadLantency"}, Include: []string{"WriteLatency"}}, []string{}},
+		{"bulk", Config{Skip: []string{"ReadLantency"}, Bulk: true}, []string{}},
+		{"proxy", Config{Include: []string{"WriteLatncy"}, TargetURL: "service:jmx:rmi:///jndi/rmi://node1:7199/jmxrmi"}, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := UnknownNames([]*Response{resp}, tt.cfg); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("UnknownNames() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderBool(t *testing.T) {
+	value := `{"org.apache.cassandra.metrics:keyspace=app,name=ReadLatency,scope=users,type=ColumnFamily": {"Count": 3, "IsInitialized": true, "IsDropped": false}}`
+	tests := []struct {
+		name string
+		cfg  Config
+		want string
+	}{
+		{"boolean", Config{Measurement: "ckc", Hostname: "node1"},
+			"ckc,cf=users,host=node1,keyspace=app,metric=ReadLatency Count=3i,IsDropped=false,IsInitialized=true 1700000000000000000"},
+		{"as int", Config{Measurement: "ckc", Hostname: "node1", BoolAsInt: true},
+			"ckc,cf=users,host=node1,keyspace=app,metric=ReadLatency Count=3i,IsDropped=0i,IsInitialized=1i 1700000000000000000"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lines, err := renderValue(t, value, tt.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(lines) != 1 || lines[0] != tt.want {
+				t.Errorf("Render() = %q, want %q", lines, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderEmptyTags(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		cfg   Config
+		want  string
+	}{
+		{"empty scope dropped", `{"org.apache.cassandra.metrics:keyspace=app,name=ReadLatency,scope=,type=ColumnFamily": {"Count": 3}}`,
+			Config{Measurement: "ckc", Hostname: "node1", DropEmptyTags: true},
+			"ckc,host=node1,keyspace=app,metric=ReadLatency Count=3i 1700000000000000000"},
+		{"empty keyspace dropped", `{"org.apache.cassandra.metrics:keyspace=,name=ReadLatency,scope=users,type=ColumnFamily": {"Count": 3}}`,
+			Config{Measurement: "ckc", Hostname: "node1", DropEmptyTags: true},
+			"ckc,cf=users,host=node1,metric=ReadLatency Count=3i 1700000000000000000"},
+		{"empty scope kept", `{"org.apache.cassandra.metrics:keyspace=app,name=ReadLatency,scope=,type=ColumnFamily": {"Count": 3}}`,
+			Config{Measurement: "ckc", Hostname: "node1"},
+			"ckc,cf=,host=node1,keyspace=app,metric=ReadLatency Count=3i 1700000000000000000"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lines, err := renderValue(t, tt.value, tt.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(lines) != 1 || lines[0] != tt.want {
+				t.Errorf("Render() = %q, want %q", lines, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderStrict(t *testing.T) {
+	const keyPath = "keyspace=app,name=ReadLatency,scope=users,type=ColumnFamily"
+	tests := []struct {
+		name    string
+		value   string
+		cfg     Config
+		wantErr string
+	}{
+		{"composite value", `{"Count": 3, "GCStats": {"CollectionCount": 5}}`, Config{}, "unexpected value of type map[string]interface {} in field GCStats of"},
+		{"non-finite value", `{"Count": 3, "Mean": 1e400}`, Config{}, "non-finite value +Inf in field Mean of"},
+		{"malformed histogram", `{"Count": 3, "RecentValues": [1, "x"]}`, Config{Histograms: true}, "bucket 1 of `RecentValues` is not a number: x in field RecentValues of"},
+	}
+	for _, tt := range tests {
+		value := `{"org.apache.cassandra.metrics:` + keyPath + `": ` + tt.value + `}`
+		t.Run(tt.name+", lenient", func(t *testing.T) {
+			cfg := tt.cfg
+			cfg.Measurement, cfg.Hostname = "ckc", "node1"
+			lines, err := renderValue(t, value, cfg)
+			if err != nil {
+				t.Fatalf("Render() error = %v, want the value skipped", err)
+			}
+			want := "ckc,cf=users,host=node1,keyspace=app,metric=ReadLatency Count=3i 1700000000000000000"
+			if len(lines) != 1 || lines[0] != want {
+				t.Errorf("Render() = %q, want %q", lines, want)
+			}
+		})
+		t.Run(tt.name+", strict", func(t *testing.T) {
+			cfg := tt.cfg
+			cfg.Measurement, cfg.Hostname, cfg.Strict = "ckc", "node1", true
+			_, err := renderValue(t, value, cfg)
+			var respErr *ResponseError
+			if !errors.As(err, &respErr) {
+				t.Fatalf("Render() error = %v, want a *ResponseError", err)
+			}
+			if want := tt.wantErr + " " + keyPath; err.Error() != want {
+				t.Errorf("Render() error = %q, want %q", err, want)
+			}
+		})
+	}
+}
+
+func TestStripMetricPrefix(t *testing.T) {
+	tests := []struct {
+		name, prefix, metric, want string
+	}{
+		{"present", "Coordinator", "CoordinatorReadLatency", "ReadLatency"},
+		{"absent", "Coordinator", "ReadLatency", "ReadLatency"},
+		{"in the middle", "Read", "CoordinatorReadLatency", "CoordinatorReadLatency"},
+		{"whole name", "ReadLatency", "ReadLatency", "ReadLatency"},
+		{"unset", "", "ReadLatency", "ReadLatency"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := stripMetricPrefix(tt.metric, Config{StripMetricPrefix: tt.prefix}); got != tt.want {
+				t.Errorf("stripMetricPrefix(%s) = %s, want %s", tt.metric, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderStripMetricPrefix(t *testing.T) {
+	value := `{
+		"org.apache.cassandra.metrics:keyspace=app,name=CoordinatorReadLatency,scope=users,type=ColumnFamily": {"Count": 3},
+		"org.apache.cassandra.metrics:keyspace=app,name=WriteLatency,scope=users,type=ColumnFamily": {"Count": 4}
+	}`
+	want := []string{
+		"ckc,cf=users,host=node1,keyspace=app,metric=ReadLatency Count=3i 1700000000000000000",
+		"ckc,cf=users,host=node1,keyspace=app,metric=WriteLatency Count=4i 1700000000000000000",
+	}
+	lines, err := renderValue(t, value, Config{Measurement: "ckc", Hostname: "node1", StripMetricPrefix: "Coordinator"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(lines)
+	if !reflect.DeepEqual(lines, want) {
+		t.Errorf("Render() = %q, want %q", lines, want)
+	}
+}
+
+func TestRenderStats(t *testing.T) {
+	value := `{
+		"org.apache.cassandra.metrics:keyspace=app,name=ReadLatency,scope=users,type=ColumnFamily": {"Count": 3},
+		"org.apache.cassandra.metrics:keyspace=app,name=WriteLatency,scope=users,type=ColumnFamily": {"Count": 0},
+		"org.apache.cassandra.metrics:keyspace=app,name=BloomFilterFalsePositives,scope=users,type=ColumnFamily": {"Count": 1},
+		"org.apache.cassandra.metrics:keyspace=system,name=ReadLatency,scope=local,type=ColumnFamily": {"Count": 2},
+		"org.apache.cassandra.metrics:keyspace=app,name=ReadLatency,scope=orders,type=ColumnFamily": {"Count": 4}
+	}`
+	cfg := Config{
+		Measurement:         "ckc",
+		Hostname:            "node1",
+		Skip:                []string{"BloomFilterFalsePositives"},
+		SkipSystemKeyspaces: true,
+		SkipZeros:           true,
+	}
+	want := Stats{Fetched: 5, SkippedByMetric: 1, SkippedByKeyspace: 1, SkippedByZeros: 1, Emitted: 2}
+
+	resp := &Response{}
+	if err := json.Unmarshal([]byte(`{"status": 200, "timestamp": 1700000000, "value": `+value+`}`), resp); err != nil {
+		t.Fatal(err)
+	}
+	lines, stats, err := RenderWithStats(resp, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats != want {
+		t.Errorf("RenderWithStats() stats = %+v, want %+v", stats, want)
+	}
+	if len(lines) != want.Emitted {
+		t.Errorf("RenderWithStats() = %d lines, want %d", len(lines), want.Emitted)
+	}
+	if got := Summarize(resp, cfg); got != want {
+		t.Errorf("Summarize() = %+v, want %+v", got, want)
+	}
+}
+
+func TestRenderMBeanTag(t *testing.T) {
+	mbean := "org.apache.cassandra.metrics:keyspace=app,name=ReadLatency,scope=users,type=ColumnFamily"
+	value := `{"` + mbean + `": {"Count": 3}}`
+	tests := []struct {
+		name string
+		cfg  Config
+		want string
+	}{
+		{"off", Config{Measurement: "ckc", Hostname: "node1"},
+			"ckc,cf=users,host=node1,keyspace=app,metric=ReadLatency Count=3i 1700000000000000000"},
+		{"on", Config{Measurement: "ckc", Hostname: "node1", EmitMBeanTag: true},
+			`ckc,cf=users,host=node1,keyspace=app,mbean=org.apache.cassandra.metrics:keyspace\=app\,name\=ReadLatency\,scope\=users\,type\=ColumnFamily,metric=ReadLatency Count=3i 1700000000000000000`},
+		{"on with the prefix stripped", Config{Measurement: "ckc", Hostname: "node1", EmitMBeanTag: true, StripMetricPrefix: "Read"},
+			`ckc,cf=users,host=node1,keyspace=app,mbean=org.apache.cassandra.metrics:keyspace\=app\,name\=ReadLatency\,scope\=users\,type\=ColumnFamily,metric=Latency Count=3i 1700000000000000000`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lines, err := renderValue(t, value, tt.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(lines) != 1 || lines[0] != tt.want {
+				t.Errorf("Render() = %q, want %q", lines, tt.want)
+			}
+		})
+	}
+}
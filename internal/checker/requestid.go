@@ -0,0 +1,38 @@
+package checker
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+)
+
+// requestIDHeader carries the id of a scrape, so its requests can be found
+// in the access logs of the proxies in front of jolokia.
+const requestIDHeader = "X-Request-ID"
+
+type requestIDKey struct{}
+
+// WithRequestID returns a context whose jolokia requests carry id.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID returns the request id of ctx, if any.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// newRequestID returns cfg.RequestID, or a random UUID when it is not set.
+func newRequestID(cfg Config) string {
+	if cfg.RequestID != "" {
+		return cfg.RequestID
+	}
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
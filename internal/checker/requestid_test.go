@@ -0,0 +1,76 @@
+package checker
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sync"
+	"testing"
+)
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+func TestNewRequestID(t *testing.T) {
+	if got := newRequestID(Config{RequestID: "deploy-42"}); got != "deploy-42" {
+		t.Errorf("newRequestID() = %q, want the configured deploy-42", got)
+	}
+	first, second := newRequestID(Config{}), newRequestID(Config{})
+	if !uuidPattern.MatchString(first) {
+		t.Errorf("newRequestID() = %q, want a random UUID", first)
+	}
+	if first == second {
+		t.Errorf("newRequestID() = %q twice, want a new id per scrape", first)
+	}
+}
+
+func TestScrapeRequestID(t *testing.T) {
+	const value = `{"status": 200, "timestamp": 1700000000, "value": {"org.apache.cassandra.metrics:keyspace=app,name=ReadLatency,scope=users,type=ColumnFamily": {"Count": 3}}}`
+	tests := []struct {
+		name      string
+		requestID string
+	}{
+		{"configured", "deploy-42"},
+		{"random", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			headers := []string{}
+			record := func(handler http.HandlerFunc) http.HandlerFunc {
+				return func(w http.ResponseWriter, r *http.Request) {
+					mu.Lock()
+					headers = append(headers, r.Header.Get("X-Request-ID"))
+					mu.Unlock()
+					handler(w, r)
+				}
+			}
+			up := stubAgent(t, record(respondWith(value)))
+			failing := stubAgent(t, record(func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, "bad gateway", http.StatusBadGateway)
+			}))
+
+			logs := captureLogs(t)
+			cfg := Config{Measurement: "ckc", Hostname: "checker", JolokiaURLs: []*url.URL{up, failing}, RequestID: tt.requestID}
+			if _, err := Scrape(context.Background(), http.DefaultClient, cfg); err != nil {
+				t.Fatalf("Scrape() error = %v", err)
+			}
+
+			if len(headers) != 2 || headers[0] == "" || headers[0] != headers[1] {
+				t.Fatalf("X-Request-ID headers = %q, want the same id sent to both agents", headers)
+			}
+			if tt.requestID != "" && headers[0] != tt.requestID {
+				t.Errorf("X-Request-ID = %q, want %q", headers[0], tt.requestID)
+			}
+			logged := regexp.MustCompile(`request_id=(\S+)`).FindAllStringSubmatch(logs.String(), -1)
+			if len(logged) != 2 {
+				t.Fatalf("logs:\n%s\nwant the request id on the summary and the failure", logs)
+			}
+			for _, match := range logged {
+				if match[1] != headers[0] {
+					t.Errorf("logged request_id=%s, want %s as sent", match[1], headers[0])
+				}
+			}
+		})
+	}
+}
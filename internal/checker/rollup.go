@@ -0,0 +1,133 @@
+package checker
+
+import (
+	"math"
+	"sort"
+	"strings"
+)
+
+// rollupTable is the cf tag of the keyspace rollups.
+const rollupTable = "_total"
+
+// rollupFunction returns how field is rolled up, the configured function or
+// one fitting its name: latencies and ratios are averaged, extremes kept and
+// everything else summed.
+func rollupFunction(key string, cfg Config) string {
+	if fn, ok := cfg.Rollup[key]; ok {
+		return fn
+	}
+	switch {
+	case key == "Max":
+		return "max"
+	case key == "Min":
+		return "min"
+	case key == "Mean", key == "StdDev", strings.HasSuffix(key, "Percentile"),
+		strings.HasSuffix(key, "Ratio"), strings.HasPrefix(key, "Mean"):
+		return "avg"
+	}
+	return "sum"
+}
+
+// rollupKeyspaces adds, for each metric of the tables of a keyspace, a
+// metric with cf=_total rolling its numeric fields up across the tables.
+// Only the fields of cfg.Rollup are rolled up when it is set. With
+// RollupOnly, the per table metrics are left out.
+func rollupKeyspaces(metrics []metric, cfg Config) []metric {
+	type accumulator struct {
+		fn       string
+		sum      float64
+		min, max float64
+		n        int
+		ints     bool
+	}
+	type rollup struct {
+		metric metric
+		keys   []string
+		fields map[string]*accumulator
+	}
+
+	index := map[string]int{}
+	rollups := []*rollup{}
+	tables := []metric{}
+	for _, m := range metrics {
+		if scopeTags[m.mbeanType] != "cf" || !m.hasTag("keyspace") || !m.hasTag("cf") {
+			tables = append(tables, m)
+			continue
+		}
+		if !cfg.RollupOnly {
+			tables = append(tables, m)
+		}
+
+		total := metric{source: m.source, keyPath: m.keyPath, mbeanType: m.mbeanType, name: m.name, timestamp: m.timestamp}
+		for _, t := range m.tags {
+			if t.key == "mbean" {
+				// Totals come from several MBeans.
+				continue
+			}
+			if t.key == "cf" {
+				t.value = rollupTable
+			}
+			total.tags = append(total.tags, t)
+		}
+		key := metric{mbeanType: total.mbeanType, tags: sortTags(append([]tag{}, total.tags...))}.seriesKey()
+		i, ok := index[key]
+		if !ok {
+			i = len(rollups)
+			index[key] = i
+			rollups = append(rollups, &rollup{metric: total, fields: map[string]*accumulator{}})
+		}
+		r := rollups[i]
+
+		for _, f := range m.fields {
+			if len(cfg.Rollup) > 0 {
+				if _, ok := cfg.Rollup[f.key]; !ok {
+					continue
+				}
+			}
+			value, ok := toFloat(f.value)
+			if !ok {
+				continue
+			}
+			acc, ok := r.fields[f.key]
+			if !ok {
+				acc = &accumulator{fn: rollupFunction(f.key, cfg), min: math.Inf(1), max: math.Inf(-1), ints: true}
+				r.fields[f.key] = acc
+				r.keys = append(r.keys, f.key)
+			}
+			acc.sum += value
+			acc.min = math.Min(acc.min, value)
+			acc.max = math.Max(acc.max, value)
+			acc.n++
+			if _, ok := f.value.(int64); !ok {
+				acc.ints = false
+			}
+		}
+	}
+
+	for _, r := range rollups {
+		for _, key := range r.keys {
+			acc := r.fields[key]
+			var value float64
+			switch acc.fn {
+			case "avg":
+				value = acc.sum / float64(acc.n)
+			case "min":
+				value = acc.min
+			case "max":
+				value = acc.max
+			default:
+				value = acc.sum
+			}
+			if acc.ints && acc.fn != "avg" {
+				r.metric.fields = append(r.metric.fields, field{key, int64(value)})
+			} else {
+				r.metric.fields = append(r.metric.fields, field{key, value})
+			}
+		}
+		sort.SliceStable(r.metric.fields, func(i, j int) bool { return r.metric.fields[i].key < r.metric.fields[j].key })
+		if len(r.metric.fields) > 0 {
+			tables = append(tables, r.metric)
+		}
+	}
+	return tables
+}
@@ -0,0 +1,124 @@
+package checker
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRollupFunction(t *testing.T) {
+	tests := []struct {
+		key    string
+		rollup map[string]string
+		want   string
+	}{
+		{"Count", nil, "sum"},
+		{"Value", nil, "sum"},
+		{"Mean", nil, "avg"},
+		{"MeanRate", nil, "avg"},
+		{"99thPercentile", nil, "avg"},
+		{"BloomFilterFalseRatio", nil, "avg"},
+		{"Max", nil, "max"},
+		{"Min", nil, "min"},
+		{"Mean", map[string]string{"Mean": "sum"}, "sum"},
+		{"Count", map[string]string{"Mean": "sum"}, "sum"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			if got := rollupFunction(tt.key, Config{Rollup: tt.rollup}); got != tt.want {
+				t.Errorf("rollupFunction(%s) = %s, want %s", tt.key, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderRollupKeyspace(t *testing.T) {
+	value := `{
+		"org.apache.cassandra.metrics:keyspace=app,name=ReadLatency,scope=users,type=ColumnFamily": {"Count": 3, "Mean": 1.5, "Max": 9},
+		"org.apache.cassandra.metrics:keyspace=app,name=ReadLatency,scope=events,type=ColumnFamily": {"Count": 5, "Mean": 2.5, "Max": 4},
+		"org.apache.cassandra.metrics:keyspace=logs,name=ReadLatency,scope=events,type=ColumnFamily": {"Count": 7, "Mean": 1, "Max": 2}
+	}`
+	tables := []string{
+		"ckc,cf=events,host=node1,keyspace=app,metric=ReadLatency Count=5i,Max=4i,Mean=2.500000 1700000000000000000",
+		"ckc,cf=events,host=node1,keyspace=logs,metric=ReadLatency Count=7i,Max=2i,Mean=1i 1700000000000000000",
+		"ckc,cf=users,host=node1,keyspace=app,metric=ReadLatency Count=3i,Max=9i,Mean=1.500000 1700000000000000000",
+	}
+	tests := []struct {
+		name string
+		cfg  Config
+		want []string
+	}{
+		{"rollup", Config{RollupKeyspace: true}, append([]string{
+			"ckc,cf=_total,host=node1,keyspace=app,metric=ReadLatency Count=8i,Max=9i,Mean=2.000000 1700000000000000000",
+			"ckc,cf=_total,host=node1,keyspace=logs,metric=ReadLatency Count=7i,Max=2i,Mean=1.000000 1700000000000000000",
+		}, tables...)},
+		{"rollup only", Config{RollupKeyspace: true, RollupOnly: true}, []string{
+			"ckc,cf=_total,host=node1,keyspace=app,metric=ReadLatency Count=8i,Max=9i,Mean=2.000000 1700000000000000000",
+			"ckc,cf=_total,host=node1,keyspace=logs,metric=ReadLatency Count=7i,Max=2i,Mean=1.000000 1700000000000000000",
+		}},
+		{"configured fields", Config{RollupKeyspace: true, RollupOnly: true, Rollup: map[string]string{"Count": "avg", "Max": "min"}}, []string{
+			"ckc,cf=_total,host=node1,keyspace=app,metric=ReadLatency Count=4.000000,Max=4i 1700000000000000000",
+			"ckc,cf=_total,host=node1,keyspace=logs,metric=ReadLatency Count=7.000000,Max=2i 1700000000000000000",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.cfg.Measurement, tt.cfg.Hostname = "ckc", "node1"
+			lines, err := renderValue(t, value, tt.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sort.Strings(lines)
+			if strings.Join(lines, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("Render() = %q, want %q", lines, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderRollupOnlyChanged(t *testing.T) {
+	value := func(a, b int) string {
+		return fmt.Sprintf(`{
+			"org.apache.cassandra.metrics:keyspace=app,name=ReadLatency,scope=a,type=ColumnFamily": {"Count": %d},
+			"org.apache.cassandra.metrics:keyspace=app,name=ReadLatency,scope=b,type=ColumnFamily": {"Count": %d}
+		}`, a, b)
+	}
+	tests := []struct {
+		name   string
+		cfg    Config
+		scrape [][2]int
+		want   [][]string
+	}{
+		{"rollup", Config{RollupKeyspace: true}, [][2]int{{3, 5}, {3, 6}}, [][]string{{
+			"ckc,cf=_total,host=node1,keyspace=app,metric=ReadLatency Count=8i 1700000000000000000",
+			"ckc,cf=a,host=node1,keyspace=app,metric=ReadLatency Count=3i 1700000000000000000",
+			"ckc,cf=b,host=node1,keyspace=app,metric=ReadLatency Count=5i 1700000000000000000",
+		}, {
+			"ckc,cf=_total,host=node1,keyspace=app,metric=ReadLatency Count=9i 1700000000000000000",
+			"ckc,cf=b,host=node1,keyspace=app,metric=ReadLatency Count=6i 1700000000000000000",
+		}}},
+		{"rollup only", Config{RollupKeyspace: true, RollupOnly: true}, [][2]int{{3, 5}, {3, 6}, {3, 6}}, [][]string{
+			{"ckc,cf=_total,host=node1,keyspace=app,metric=ReadLatency Count=8i 1700000000000000000"},
+			{"ckc,cf=_total,host=node1,keyspace=app,metric=ReadLatency Count=9i 1700000000000000000"},
+			nil,
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := tt.cfg
+			cfg.Measurement, cfg.Hostname, cfg.Changes = "ckc", "node1", NewChanges(time.Hour)
+			for i, counts := range tt.scrape {
+				lines, err := renderValue(t, value(counts[0], counts[1]), cfg)
+				if err != nil {
+					t.Fatal(err)
+				}
+				sort.Strings(lines)
+				if strings.Join(lines, "\n") != strings.Join(tt.want[i], "\n") {
+					t.Errorf("scrape %d: Render() = %q, want %q", i, lines, tt.want[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package checker
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// Scrape fetches from every configured jolokia agent and renders their
+// metrics followed by the heartbeat. Agents that fail are logged and left
+// out; only when all of them fail is an error returned, along with the
+// heartbeat reporting the failures.
+func Scrape(ctx context.Context, client *http.Client, cfg Config) ([]string, error) {
+	lines, err := ScrapeFormats(ctx, client, cfg, []string{cfg.OutputFormat})
+	return lines[cfg.OutputFormat], err
+}
+
+// ScrapeFormats works as Scrape, rendering the same series in each of the
+// formats, keyed by format.
+func ScrapeFormats(ctx context.Context, client *http.Client, cfg Config, formats []string) (map[string][]string, error) {
+	start := time.Now()
+	ctx = WithRequestID(ctx, newRequestID(cfg))
+	id := RequestID(ctx)
+	results := FetchAll(ctx, client, cfg)
+
+	resps := []*Response{}
+	errs := []error{}
+	for _, result := range results {
+		if result.Err != nil {
+			errs = append(errs, result.Err)
+			continue
+		}
+		resps = append(resps, result.Response)
+	}
+
+	if cfg.WarnUnknownSkips && len(resps) > 0 {
+		for _, name := range UnknownNames(resps, cfg) {
+			slog.Warn("Filtered metric name matches no metric", "name", name)
+		}
+	}
+
+	now := time.Now()
+	if !cfg.FixedTimestamp.IsZero() {
+		now = cfg.FixedTimestamp
+	}
+
+	metrics, stats, err := collectAll(resps, cfg)
+	if err != nil {
+		// No series are written, but the heartbeat still tells every node
+		// failed, as with a failed fetch.
+		statuses := []ScrapeStatus{}
+		for _, result := range results {
+			statuses = append(statuses, ScrapeStatus{Node: result.Node, Tags: result.Tags, Duration: result.Duration})
+		}
+		outputs := map[string][]string{}
+		for _, format := range formats {
+			cfg := cfg
+			cfg.OutputFormat = format
+			outputs[format] = Heartbeat(cfg, statuses, now)
+		}
+		return outputs, err
+	}
+
+	// The summary tells where the entries went, to find out why a metric
+	// is missing without going through the debug log of every entry.
+	total := Stats{}
+	for _, s := range stats {
+		total.add(s)
+	}
+	exceeded := total.SkippedByMaxSeries > 0
+	slog.Info("Scraped the jolokia agents", "agents", len(results), "failed", len(errs),
+		"fetched", total.Fetched, "skipped_by_metric", total.SkippedByMetric,
+		"skipped_by_keyspace", total.SkippedByKeyspace, "skipped_by_table", total.SkippedByTable,
+		"skipped_by_sampling", total.SkippedBySampling, "skipped_by_zeros", total.SkippedByZeros,
+		"skipped_by_min_value", total.SkippedByMinValue, "skipped_unchanged", total.SkippedUnchanged,
+		"skipped_inactive", total.SkippedInactive,
+		"skipped_by_max_series", total.SkippedByMaxSeries,
+		"series", total.Emitted, "duration", time.Since(start), "request_id", id)
+
+	statuses := []ScrapeStatus{}
+	for _, result := range results {
+		status := ScrapeStatus{Node: result.Node, Tags: result.Tags, Up: result.Err == nil, Duration: result.Duration,
+			CardinalityExceeded: exceeded}
+		if status.Up {
+			status.SeriesCount = stats[0].Emitted
+			status.RequestDuration = result.Response.RequestDuration
+			stats = stats[1:]
+		}
+		statuses = append(statuses, status)
+	}
+	outputs := map[string][]string{}
+	for _, format := range formats {
+		cfg := cfg
+		cfg.OutputFormat = format
+		heartbeat := Heartbeat(cfg, statuses, now)
+		if len(resps) == 0 {
+			outputs[format] = heartbeat
+			continue
+		}
+		lines, err := formatLines(metrics, cfg)
+		if err != nil {
+			return nil, err
+		}
+		outputs[format] = append(lines, heartbeat...)
+	}
+
+	if len(resps) == 0 {
+		return outputs, errors.Join(errs...)
+	}
+	for _, result := range results {
+		if result.Err != nil {
+			slog.Error("Scrape failed", "url", result.URL.String(), "request_id", id, "error", result.Err)
+		}
+	}
+	return outputs, nil
+}
@@ -0,0 +1,223 @@
+package checker
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestScrapeAgents(t *testing.T) {
+	users := stubAgent(t, respondWith(`{"status": 200, "timestamp": 1700000000, "value": {"org.apache.cassandra.metrics:keyspace=app,name=ReadLatency,scope=users,type=ColumnFamily": {"Count": 3}}}`))
+	events := stubAgent(t, respondWith(`{"status": 200, "timestamp": 1700000000, "value": {"org.apache.cassandra.metrics:keyspace=app,name=WriteLatency,scope=events,type=ColumnFamily": {"Count": 5}}}`))
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	down, _ := url.Parse("http://" + listener.Addr().String() + "/jolokia")
+	listener.Close()
+
+	cfg := Config{
+		Measurement:    "ckc",
+		Hostname:       "checker",
+		JolokiaURLs:    []*url.URL{users, down, events},
+		Concurrency:    2,
+		FixedTimestamp: time.Unix(1700000000, 0),
+	}
+	lines, err := Scrape(context.Background(), http.DefaultClient, cfg)
+	if err != nil {
+		t.Fatalf("Scrape() error = %v, want none while an agent is up", err)
+	}
+	want := []string{
+		fmt.Sprintf("ckc,cf=users,host=checker,keyspace=app,metric=ReadLatency,node=%s Count=3i 1700000000000000000", users.Host),
+		fmt.Sprintf("ckc,cf=events,host=checker,keyspace=app,metric=WriteLatency,node=%s Count=5i 1700000000000000000", events.Host),
+		fmt.Sprintf("cassandra_keyspaces_checker,host=checker,node=%s scrape_duration_ms=", users.Host),
+		fmt.Sprintf("cassandra_keyspaces_checker,host=checker,node=%s scrape_duration_ms=", down.Host),
+		fmt.Sprintf("cassandra_keyspaces_checker,host=checker,node=%s scrape_duration_ms=", events.Host),
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("Scrape() = %q, want %d lines", lines, len(want))
+	}
+	for i := range want {
+		if !strings.HasPrefix(lines[i], want[i]) {
+			t.Errorf("line %d = %q, want it starting with %q", i, lines[i], want[i])
+		}
+	}
+	if !strings.Contains(lines[3], "up=0i") {
+		t.Errorf("heartbeat of the agent down = %q, want up=0i", lines[3])
+	}
+}
+
+func TestScrapeAllAgentsDown(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	down, _ := url.Parse("http://" + listener.Addr().String() + "/jolokia")
+	listener.Close()
+
+	lines, err := Scrape(context.Background(), http.DefaultClient, Config{Hostname: "checker", JolokiaURLs: []*url.URL{down}})
+	if err == nil {
+		t.Error("Scrape() error = nil, want the failure of the only agent")
+	}
+	if len(lines) != 1 || !strings.Contains(lines[0], "up=0i") {
+		t.Errorf("Scrape() = %q, want only the heartbeat with up=0i", lines)
+	}
+}
+
+func TestScrapeStrictHeartbeat(t *testing.T) {
+	baseURL := stubAgent(t, respondWith(`{"status": 200, "timestamp": 1700000000, "value": {"org.apache.cassandra.metrics:keyspace=app,name=ReadLatency,scope=users,type=ColumnFamily": {"Count": 3, "GCStats": {"CollectionCount": 5}}}}`))
+	cfg := Config{Measurement: "ckc", Hostname: "checker", JolokiaURLs: []*url.URL{baseURL}, Strict: true}
+	outputs, err := ScrapeFormats(context.Background(), http.DefaultClient, cfg, []string{"influx", "prometheus"})
+	if err == nil {
+		t.Fatal("ScrapeFormats() error = nil, want the strict failure")
+	}
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{"influx", "up=0i"},
+		{"prometheus", "cassandra_keyspaces_checker_up{host=\"checker\"} 0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			lines := strings.Join(outputs[tt.format], "\n")
+			if !strings.Contains(lines, tt.want) {
+				t.Errorf("ScrapeFormats() %s = %q, want the heartbeat with %s", tt.format, lines, tt.want)
+			}
+			if strings.Contains(lines, "ReadLatency") {
+				t.Errorf("ScrapeFormats() %s = %q, want no series", tt.format, lines)
+			}
+		})
+	}
+}
+
+func TestNodeName(t *testing.T) {
+	tests := []struct {
+		url, want string
+	}{
+		{"http://cassandra-1:8778/jolokia", "cassandra-1:8778"},
+		{"http://cassandra-1/jolokia", "cassandra-1"},
+		{"http://[::1]:8778/jolokia", "[::1]:8778"},
+		{"http://[::1]/jolokia", "::1"},
+		{"https://[2001:db8::7]:8443/jolokia", "[2001:db8::7]:8443"},
+		{"http://[fe80::1%25eth0]:8778/jolokia", "[fe80::1%eth0]:8778"},
+	}
+	for _, tt := range tests {
+		u, _ := url.Parse(tt.url)
+		if got := nodeName(u); got != tt.want {
+			t.Errorf("nodeName(%s) = %q, want %q", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestScrapeIPv6(t *testing.T) {
+	listener, err := net.Listen("tcp6", "[::1]:0")
+	if err != nil {
+		t.Skipf("no IPv6 loopback: %s", err)
+	}
+	server := httptest.NewUnstartedServer(respondWith(`{"status": 200, "timestamp": 1700000000, "value": {"org.apache.cassandra.metrics:keyspace=app,name=ReadLatency,scope=users,type=ColumnFamily": {"Count": 3}}}`))
+	server.Listener.Close()
+	server.Listener = listener
+	server.Start()
+	defer server.Close()
+
+	port := listener.Addr().(*net.TCPAddr).Port
+	baseURL, err := url.Parse(fmt.Sprintf("http://[::1]:%d/jolokia", port))
+	if err != nil {
+		t.Fatal(err)
+	}
+	other := stubAgent(t, respondWith(`{"status": 200, "timestamp": 1700000000, "value": {}}`))
+	cfg := Config{Measurement: "ckc", Hostname: "checker", JolokiaURLs: []*url.URL{baseURL, other}}
+	lines, err := Scrape(context.Background(), http.DefaultClient, cfg)
+	if err != nil {
+		t.Fatalf("Scrape() error = %v", err)
+	}
+	want := fmt.Sprintf("ckc,cf=users,host=checker,keyspace=app,metric=ReadLatency,node=[::1]:%d Count=3i 1700000000000000000", port)
+	if len(lines) == 0 || lines[0] != want {
+		t.Errorf("Scrape() = %q, want it starting with %q", lines, want)
+	}
+}
+
+func TestScrapeMaxSeries(t *testing.T) {
+	agent := stubAgent(t, respondWith(`{"status": 200, "timestamp": 1700000000, "value": {
+		"org.apache.cassandra.metrics:keyspace=app,name=ReadLatency,scope=a,type=ColumnFamily": {"Count": 1},
+		"org.apache.cassandra.metrics:keyspace=app,name=ReadLatency,scope=b,type=ColumnFamily": {"Count": 2},
+		"org.apache.cassandra.metrics:keyspace=app,name=ReadLatency,scope=c,type=ColumnFamily": {"Count": 3},
+		"org.apache.cassandra.metrics:keyspace=app,name=WriteLatency,scope=a,type=ColumnFamily": {"Count": 4}
+	}}`))
+	tests := []struct {
+		name          string
+		maxSeries     int
+		wantSeries    int
+		wantExceeded  string
+		wantTruncated bool
+	}{
+		{"exceeded", 2, 2, "cardinality_exceeded=1i", true},
+		{"at the limit after filtering", 3, 3, "cardinality_exceeded=0i", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logs := captureLogs(t)
+			cfg := Config{Measurement: "ckc", Hostname: "checker", JolokiaURLs: []*url.URL{agent},
+				Skip: []string{"WriteLatency"}, MaxSeries: tt.maxSeries, FixedTimestamp: time.Unix(1700000000, 0)}
+			lines, err := Scrape(context.Background(), http.DefaultClient, cfg)
+			if err != nil {
+				t.Fatalf("Scrape() error = %v", err)
+			}
+			if len(lines) != tt.wantSeries+1 {
+				t.Fatalf("Scrape() = %q, want %d series and the heartbeat", lines, tt.wantSeries)
+			}
+			for _, line := range lines[:tt.wantSeries] {
+				if !strings.HasPrefix(line, "ckc,") {
+					t.Errorf("line %q, want a series", line)
+				}
+			}
+			heartbeat := lines[tt.wantSeries]
+			if !strings.Contains(heartbeat, tt.wantExceeded) || !strings.Contains(heartbeat, fmt.Sprintf("series_count=%di", tt.wantSeries)) {
+				t.Errorf("heartbeat = %q, want %s and series_count=%di", heartbeat, tt.wantExceeded, tt.wantSeries)
+			}
+			warned := strings.Contains(logs.String(), "Too many series") && strings.Contains(logs.String(), "series=3")
+			if warned != tt.wantTruncated {
+				t.Errorf("warned = %v, want %v; logs:\n%s", warned, tt.wantTruncated, logs)
+			}
+		})
+	}
+}
+
+func TestScrapeTiming(t *testing.T) {
+	body := `{"status": 200, "timestamp": 1700000000, "value": {"org.apache.cassandra.metrics:keyspace=app,name=ReadLatency,scope=users,type=ColumnFamily": {"Count": 3}}}`
+	slow := stubAgent(t, func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(50 * time.Millisecond)
+		respondWith(body)(w, r)
+	})
+
+	resp, err := Fetch(context.Background(), http.DefaultClient, slow, Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.RequestDuration < 50*time.Millisecond {
+		t.Errorf("RequestDuration = %s, want at least the 50ms delay", resp.RequestDuration)
+	}
+
+	lines, err := Scrape(context.Background(), http.DefaultClient, Config{Hostname: "node1", JolokiaURLs: []*url.URL{slow}, EmitScrapeTiming: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	heartbeat := lines[len(lines)-1]
+	_, fields, _ := strings.Cut(heartbeat, " ")
+	for _, f := range strings.Split(strings.Fields(fields)[0], ",") {
+		if key, value, _ := strings.Cut(f, "="); key == "jolokia_ms" {
+			if value == "0i" {
+				t.Errorf("jolokia_ms = %s, want a nonzero timing", value)
+			}
+			return
+		}
+	}
+	t.Errorf("heartbeat = %q, want a jolokia_ms field", heartbeat)
+}
@@ -0,0 +1,44 @@
+package checker
+
+import "strings"
+
+var (
+	statsdEscaper         = strings.NewReplacer(",", "_", "|", "_", "#", "_", ":", "_", "\n", "_", " ", "_")
+	statsdTagValueEscaper = strings.NewReplacer(",", "_", "|", "_", "#", "_", "\n", "_")
+)
+
+// statsdLines renders the metrics as DogStatsD gauges, as
+// `cassandra.<type>.<metric>.<field>:<value>|g|#<tag>:<value>,...`. String
+// fields have no StatsD representation and are left out.
+func statsdLines(metrics []metric, cfg Config) []string {
+	lines := []string{}
+	for _, m := range metrics {
+		tags := []tag{}
+		for _, t := range m.tags {
+			if t.key != "metric" && t.key != "type" {
+				tags = append(tags, t)
+			}
+		}
+		prefix := "cassandra." + statsdEscaper.Replace(strings.ToLower(m.mbeanType)) + "." + statsdEscaper.Replace(m.name)
+
+		for _, f := range m.fields {
+			value, ok := numberString(f.value)
+			if !ok {
+				continue
+			}
+			lines = append(lines, statsdLine(prefix+"."+statsdEscaper.Replace(f.key), value, tags, cfg))
+		}
+	}
+	return lines
+}
+
+// statsdLine formats a gauge, adding the static tags to the given ones.
+func statsdLine(name, value string, tags []tag, cfg Config) string {
+	pairs := []string{}
+	for _, t := range sortTags(append(staticTags(cfg), tags...)) {
+		// Only the first colon separates the key from the value, so values
+		// may keep theirs.
+		pairs = append(pairs, statsdEscaper.Replace(t.key)+":"+statsdTagValueEscaper.Replace(t.value))
+	}
+	return name + ":" + value + "|g|#" + strings.Join(pairs, ",")
+}
@@ -0,0 +1,46 @@
+package checker
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestStatsdLine(t *testing.T) {
+	tests := []struct {
+		name string
+		tags []tag
+		cfg  Config
+		want string
+	}{
+		{"sorted tags", []tag{{"keyspace", "app"}, {"cf", "users"}}, Config{Hostname: "node1"},
+			"cassandra.columnfamily.ReadLatency.Count:3|g|#cf:users,host:node1,keyspace:app"},
+		{"static tags", []tag{{"keyspace", "app"}}, Config{Hostname: "node1", Tags: map[string]string{"dc": "eu1"}},
+			"cassandra.columnfamily.ReadLatency.Count:3|g|#dc:eu1,host:node1,keyspace:app"},
+		{"invalid characters", []tag{{"keyspace", "a|b"}, {"cf", "x,y:z"}}, Config{Hostname: "node1"},
+			"cassandra.columnfamily.ReadLatency.Count:3|g|#cf:x_y:z,host:node1,keyspace:a_b"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := statsdLine("cassandra.columnfamily.ReadLatency.Count", "3", tt.tags, tt.cfg); got != tt.want {
+				t.Errorf("statsdLine() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStatsdLines(t *testing.T) {
+	value := `{
+		"org.apache.cassandra.metrics:keyspace=app,name=ReadLatency,scope=users,type=ColumnFamily": {"Mean": 1.5, "DurationUnit": "microseconds", "Count": 3}
+	}`
+	want := []string{
+		"cassandra.columnfamily.ReadLatency.Count:3|g|#cf:users,host:node1,keyspace:app",
+		"cassandra.columnfamily.ReadLatency.Mean:1.5|g|#cf:users,host:node1,keyspace:app",
+	}
+	lines, err := renderValue(t, value, Config{Hostname: "node1", OutputFormat: "statsd"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(lines, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Render() = %q, want %q", lines, want)
+	}
+}
@@ -0,0 +1,97 @@
+package checker
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// tableLines renders the metrics as an aligned text table, one row per
+// series sorted by keyspace, table and metric, and one column per field.
+// It is meant to be read, not ingested.
+func tableLines(metrics []metric, cfg Config) []string {
+	type row struct {
+		node, keyspace, cf, name string
+		values                   map[string]string
+	}
+
+	rows := []row{}
+	columns := []string{}
+	seen := map[string]bool{}
+	withNode := false
+	for _, m := range metrics {
+		r := row{name: m.name, values: map[string]string{}}
+		if m.mbeanType != DefaultMBeanType {
+			r.name = m.mbeanType + "." + m.name
+		}
+		for _, t := range m.tags {
+			switch t.key {
+			case "node":
+				r.node = t.value
+				withNode = true
+			case "keyspace":
+				r.keyspace = t.value
+			case "cf":
+				r.cf = t.value
+			}
+		}
+		for _, f := range m.fields {
+			value, ok := numberString(f.value)
+			if !ok {
+				value = fmt.Sprint(f.value)
+			}
+			r.values[f.key] = value
+			if !seen[f.key] {
+				seen[f.key] = true
+				columns = append(columns, f.key)
+			}
+		}
+		rows = append(rows, r)
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+	sort.Strings(columns)
+	sort.SliceStable(rows, func(i, j int) bool {
+		a, b := rows[i], rows[j]
+		if a.keyspace != b.keyspace {
+			return a.keyspace < b.keyspace
+		}
+		if a.cf != b.cf {
+			return a.cf < b.cf
+		}
+		if a.name != b.name {
+			return a.name < b.name
+		}
+		return a.node < b.node
+	})
+
+	orDash := func(s string) string {
+		if s == "" {
+			return "-"
+		}
+		return s
+	}
+
+	var buf bytes.Buffer
+	w := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	header := []string{"KEYSPACE", "CF", "METRIC"}
+	if withNode {
+		header = append([]string{"NODE"}, header...)
+	}
+	fmt.Fprintln(w, strings.Join(append(header, columns...), "\t"))
+	for _, r := range rows {
+		cells := []string{orDash(r.keyspace), orDash(r.cf), r.name}
+		if withNode {
+			cells = append([]string{r.node}, cells...)
+		}
+		for _, column := range columns {
+			cells = append(cells, orDash(r.values[column]))
+		}
+		fmt.Fprintln(w, strings.Join(cells, "\t"))
+	}
+	w.Flush()
+	return strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+}
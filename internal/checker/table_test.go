@@ -0,0 +1,47 @@
+package checker
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTableLines(t *testing.T) {
+	value := `{
+		"org.apache.cassandra.metrics:keyspace=app,name=ReadLatency,scope=users,type=ColumnFamily": {"Count": 3, "Mean": 1.5},
+		"org.apache.cassandra.metrics:keyspace=app,name=WriteLatency,scope=users,type=ColumnFamily": {"Count": 0},
+		"org.apache.cassandra.metrics:keyspace=app,name=ReadLatency,scope=events,type=ColumnFamily": {"Count": 7}
+	}`
+	tests := []struct {
+		name string
+		cfg  Config
+		want []string
+	}{
+		{"all", Config{OutputFormat: "table"}, []string{
+			"KEYSPACE  CF      METRIC        Count  Mean",
+			"app       events  ReadLatency   7      -",
+			"app       users   ReadLatency   3      1.5",
+			"app       users   WriteLatency  0      -",
+		}},
+		{"skip zeros", Config{OutputFormat: "table", SkipZeros: true}, []string{
+			"KEYSPACE  CF      METRIC       Count  Mean",
+			"app       events  ReadLatency  7      -",
+			"app       users   ReadLatency  3      1.5",
+		}},
+		{"filtered", Config{OutputFormat: "table", Skip: []string{"ReadLatency"}}, []string{
+			"KEYSPACE  CF     METRIC        Count",
+			"app       users  WriteLatency  0",
+		}},
+		{"nothing left", Config{OutputFormat: "table", Tables: []string{"absent"}}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lines, err := renderValue(t, value, tt.cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Join(lines, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("Render() =\n%s\nwant\n%s", strings.Join(lines, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
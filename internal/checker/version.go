@@ -0,0 +1,51 @@
+package checker
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// AgentVersion is what the jolokia version endpoint reports about the agent.
+type AgentVersion struct {
+	Agent    string
+	Protocol string
+	// Config holds the agent settings, such as maxDepth and maxObjects.
+	Config map[string]string
+}
+
+// ProbeVersion reads the version and settings of the jolokia agent at
+// baseURL.
+func ProbeVersion(ctx context.Context, client *http.Client, baseURL *url.URL, cfg Config) (*AgentVersion, error) {
+	if cfg.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.Timeout)
+		defer cancel()
+	}
+
+	jsonResp := struct {
+		Status int    `json:"status"`
+		Error  string `json:"error"`
+		Value  struct {
+			Agent    string                 `json:"agent"`
+			Protocol string                 `json:"protocol"`
+			Config   map[string]interface{} `json:"config"`
+		} `json:"value"`
+	}{}
+	if err := doRequest(ctx, client, http.MethodGet, baseURL.JoinPath("version"), nil, cfg, &jsonResp); err != nil {
+		return nil, err
+	}
+	if jsonResp.Status != 200 || jsonResp.Error != "" {
+		return nil, fmt.Errorf("jolokia status %d: %s", jsonResp.Status, jsonResp.Error)
+	}
+	if jsonResp.Value.Agent == "" {
+		return nil, fmt.Errorf("no agent version in the jolokia response")
+	}
+
+	version := &AgentVersion{Agent: jsonResp.Value.Agent, Protocol: jsonResp.Value.Protocol, Config: map[string]string{}}
+	for key, value := range jsonResp.Value.Config {
+		version.Config[key] = fmt.Sprint(value)
+	}
+	return version, nil
+}
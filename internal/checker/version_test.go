@@ -0,0 +1,42 @@
+package checker
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestProbeVersion(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		want    *AgentVersion
+		wantErr bool
+	}{
+		{"version", respondWith(`{"status": 200, "value": {"agent": "1.7.2", "protocol": "7.2", "config": {"maxDepth": "15", "maxObjects": 0}}}`),
+			&AgentVersion{Agent: "1.7.2", Protocol: "7.2", Config: map[string]string{"maxDepth": "15", "maxObjects": "0"}}, false},
+		{"no config", respondWith(`{"status": 200, "value": {"agent": "2.0.0", "protocol": "7.3"}}`),
+			&AgentVersion{Agent: "2.0.0", Protocol: "7.3", Config: map[string]string{}}, false},
+		{"jolokia error", respondWith(`{"status": 403, "error": "access denied"}`), nil, true},
+		{"no agent", respondWith(`{"status": 200, "value": {}}`), nil, true},
+		{"not found", http.NotFound, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			baseURL := stubAgent(t, func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/jolokia/version" {
+					t.Errorf("path = %s, want /jolokia/version", r.URL.Path)
+				}
+				tt.handler(w, r)
+			})
+			got, err := ProbeVersion(context.Background(), http.DefaultClient, baseURL, Config{})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ProbeVersion() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ProbeVersion() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"strconv"
+	"time"
+)
+
+// jitter spreads the daemon scrapes of a fleet over time, so instances
+// started together do not hit the jolokia agents, or a shared proxy, at the
+// same moment. The randomness is seeded with the hostname, so an instance
+// keeps its offset across restarts while different hosts get different ones.
+type jitter struct {
+	max time.Duration
+	// each also randomizes every interval, not only the first scrape.
+	each bool
+	rng  *rand.Rand
+}
+
+func newJitter(max time.Duration, each bool, hostname string) *jitter {
+	h := fnv.New64a()
+	h.Write([]byte(hostname))
+	return &jitter{max: max, each: each, rng: rand.New(rand.NewSource(int64(h.Sum64())))}
+}
+
+// first returns the delay of the first scrape, within [0, max).
+func (j *jitter) first() time.Duration {
+	if j.max <= 0 {
+		return 0
+	}
+	return time.Duration(j.rng.Int63n(int64(j.max)))
+}
+
+// next returns delay moved by up to half of max either way when each is
+// set, so the mean interval stays the same.
+func (j *jitter) next(delay time.Duration) time.Duration {
+	if !j.each || j.max <= 0 {
+		return delay
+	}
+	delay += time.Duration(j.rng.Int63n(int64(j.max))) - j.max/2
+	if delay < 0 {
+		return 0
+	}
+	return delay
+}
+
+// parseJitter parses --interval-jitter, either a fraction of interval below
+// 1, such as 0.1, or a duration no longer than interval.
+func parseJitter(value string, interval time.Duration) (time.Duration, error) {
+	if f, err := strconv.ParseFloat(value, 64); err == nil {
+		if f < 0 || f >= 1 {
+			return 0, fmt.Errorf("fraction %v is not within [0, 1)", f)
+		}
+		return time.Duration(f * float64(interval)), nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("expected a fraction of the interval or a duration: %w", err)
+	}
+	if d < 0 || d > interval {
+		return 0, fmt.Errorf("duration %s is not within [0, --interval]", d)
+	}
+	return d, nil
+}
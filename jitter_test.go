@@ -0,0 +1,74 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseJitter(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"0", 0, false},
+		{"0.1", 6 * time.Second, false},
+		{"15s", 15 * time.Second, false},
+		{"1m", time.Minute, false},
+		{"1", 0, true},
+		{"-0.1", 0, true},
+		{"2m", 0, true},
+		{"soon", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := parseJitter(tt.value, time.Minute)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseJitter() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseJitter() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJitterRange(t *testing.T) {
+	const interval = time.Minute
+	tests := []struct {
+		name               string
+		max                time.Duration
+		each               bool
+		firstMin, firstMax time.Duration
+		nextMin, nextMax   time.Duration
+	}{
+		{"disabled", 0, true, 0, 0, interval, interval},
+		{"first only", 10 * time.Second, false, 0, 10 * time.Second, interval, interval},
+		{"each interval", 10 * time.Second, true, 0, 10 * time.Second, interval - 5*time.Second, interval + 5*time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			j := newJitter(tt.max, tt.each, "node1")
+			for i := 0; i < 1000; i++ {
+				if got := j.first(); got < tt.firstMin || got > tt.firstMax {
+					t.Fatalf("first() = %s, want within [%s, %s]", got, tt.firstMin, tt.firstMax)
+				}
+				if got := j.next(interval); got < tt.nextMin || got > tt.nextMax {
+					t.Fatalf("next() = %s, want within [%s, %s]", got, tt.nextMin, tt.nextMax)
+				}
+			}
+		})
+	}
+}
+
+func TestJitterSeed(t *testing.T) {
+	first := func(hostname string) time.Duration {
+		return newJitter(time.Minute, false, hostname).first()
+	}
+	if first("node1") != first("node1") {
+		t.Error("first() differs between two instances on the same host")
+	}
+	if first("node1") == first("node2") {
+		t.Error("first() is the same on different hosts")
+	}
+}
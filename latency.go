@@ -0,0 +1,31 @@
+package main
+
+var (
+	// latencyMetrics are the metrics collected by --latency-only.
+	latencyMetrics = []string{"ReadLatency", "WriteLatency", "RangeLatency"}
+	// latencyFields are the percentile attributes of the latency metrics.
+	latencyFields = []string{
+		"50thPercentile",
+		"75thPercentile",
+		"95thPercentile",
+		"98thPercentile",
+		"99thPercentile",
+		"999thPercentile",
+	}
+)
+
+// latencyPreset returns the include and fields filters. When enabled, as by
+// --latency-only, they default to the latency metrics and their percentiles
+// unless given explicitly.
+func latencyPreset(enabled bool, include, fields []string) ([]string, []string) {
+	if !enabled {
+		return include, fields
+	}
+	if len(include) == 0 {
+		include = latencyMetrics
+	}
+	if len(fields) == 0 {
+		fields = latencyFields
+	}
+	return include, fields
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"log/syslog"
+	"os"
+	"strings"
+)
+
+var syslogFacilities = map[string]syslog.Priority{
+	"kern":     syslog.LOG_KERN,
+	"user":     syslog.LOG_USER,
+	"mail":     syslog.LOG_MAIL,
+	"daemon":   syslog.LOG_DAEMON,
+	"auth":     syslog.LOG_AUTH,
+	"syslog":   syslog.LOG_SYSLOG,
+	"lpr":      syslog.LOG_LPR,
+	"news":     syslog.LOG_NEWS,
+	"uucp":     syslog.LOG_UUCP,
+	"cron":     syslog.LOG_CRON,
+	"authpriv": syslog.LOG_AUTHPRIV,
+	"ftp":      syslog.LOG_FTP,
+	"local0":   syslog.LOG_LOCAL0,
+	"local1":   syslog.LOG_LOCAL1,
+	"local2":   syslog.LOG_LOCAL2,
+	"local3":   syslog.LOG_LOCAL3,
+	"local4":   syslog.LOG_LOCAL4,
+	"local5":   syslog.LOG_LOCAL5,
+	"local6":   syslog.LOG_LOCAL6,
+	"local7":   syslog.LOG_LOCAL7,
+}
+
+var syslogSeverities = map[string]syslog.Priority{
+	"emerg":   syslog.LOG_EMERG,
+	"alert":   syslog.LOG_ALERT,
+	"crit":    syslog.LOG_CRIT,
+	"err":     syslog.LOG_ERR,
+	"warning": syslog.LOG_WARNING,
+	"notice":  syslog.LOG_NOTICE,
+	"info":    syslog.LOG_INFO,
+	"debug":   syslog.LOG_DEBUG,
+}
+
+var logLevels = map[string]slog.Level{
+	"error": slog.LevelError,
+	"warn":  slog.LevelWarn,
+	"info":  slog.LevelInfo,
+	"debug": slog.LevelDebug,
+}
+
+// minLogLevel returns the level of --log-level, lowered to debug by --debug
+// and raised to error by --quiet.
+func minLogLevel(name string, debug, quiet bool) slog.Level {
+	switch {
+	case quiet:
+		return slog.LevelError
+	case debug:
+		return slog.LevelDebug
+	}
+	return logLevels[name]
+}
+
+// setupLogging routes the logs to stderr or syslog, formatted either as
+// plain text or as one JSON object per line.
+func setupLogging() error {
+	var w io.Writer = os.Stderr
+	if !*stderr {
+		facility, ok := syslogFacilities[strings.ToLower(strings.TrimPrefix(*syslogFacility, "LOG_"))]
+		if !ok {
+			return fmt.Errorf("unknown syslog facility %q", *syslogFacility)
+		}
+		severity, ok := syslogSeverities[strings.ToLower(strings.TrimPrefix(*syslogSeverity, "LOG_"))]
+		if !ok {
+			return fmt.Errorf("unknown syslog severity %q", *syslogSeverity)
+		}
+		sw, err := syslog.New(severity|facility, *syslogTag)
+		if err != nil {
+			return err
+		}
+		w = sw
+	}
+
+	level := minLogLevel(*logLevel, *debug, *quiet)
+
+	switch *logFormat {
+	case "json":
+		slog.SetDefault(slog.New(slog.NewJSONHandler(w, &slog.HandlerOptions{Level: level})))
+	default:
+		if level == slog.LevelDebug {
+			log.SetFlags(log.LstdFlags | log.Lshortfile)
+		}
+		log.SetOutput(w)
+		slog.SetLogLoggerLevel(level)
+	}
+	return nil
+}
+
+// fatal logs msg at error level and exits with code.
+func fatal(code int, msg string, args ...interface{}) {
+	slog.Error(msg, args...)
+	os.Exit(code)
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestMinLogLevel(t *testing.T) {
+	tests := []struct {
+		name         string
+		level        string
+		debug, quiet bool
+		want         slog.Level
+	}{
+		{"warn", "warn", false, false, slog.LevelWarn},
+		{"info", "info", false, false, slog.LevelInfo},
+		{"debug overrides the level", "warn", true, false, slog.LevelDebug},
+		{"quiet overrides the level", "info", false, true, slog.LevelError},
+		{"quiet overrides debug", "info", true, true, slog.LevelError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := minLogLevel(tt.level, tt.debug, tt.quiet); got != tt.want {
+				t.Errorf("minLogLevel(%q, %v, %v) = %s, want %s", tt.level, tt.debug, tt.quiet, got, tt.want)
+			}
+		})
+	}
+}
+
+// Normal operation logs nothing, as the scrape summaries are at info.
+func TestDefaultLogLevel(t *testing.T) {
+	if got := app.GetFlag("log-level").Model().Default; len(got) != 1 || got[0] != "warn" {
+		t.Errorf("--log-level default = %v, want warn", got)
+	}
+}
+
+func TestLogLevelFlags(t *testing.T) {
+	const summary = "Scraped the jolokia agents"
+	tests := []struct {
+		name string
+		args []string
+		want bool
+	}{
+		{"info", []string{"--log-level", "info"}, true},
+		{"debug", []string{"--log-level", "error", "--debug"}, true},
+		{"default", nil, false},
+		{"error", []string{"--log-level", "error"}, false},
+		{"quiet", []string{"--log-level", "info", "--quiet"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, _, stderr := runMain(t, append([]string{"--stderr", "--jolokia", stubJolokia(t)}, tt.args...)...)
+			if code != 0 {
+				t.Fatalf("exit code = %d, stderr: %s", code, stderr)
+			}
+			if got := strings.Contains(stderr, summary); got != tt.want {
+				t.Errorf("logged %q = %v, want %v; stderr: %s", summary, got, tt.want, stderr)
+			}
+		})
+	}
+}
@@ -2,29 +2,43 @@
 package main
 
 import (
-	"encoding/json"
-	"fmt"
-	"log"
-	"log/syslog"
-	"net/http"
-	"net/url"
+	"context"
+	"log/slog"
 	"os"
+	"os/signal"
 	"path"
-	"reflect"
-	"strings"
+	"strconv"
+	"syscall"
 	"time"
 
+	"github.com/CrossEngage/cassandra-keyspaces-checker/internal/checker"
 	"gopkg.in/alecthomas/kingpin.v2"
 )
 
 var (
 	appName        = path.Base(os.Args[0])
 	app            = kingpin.New(appName, "A telegraf input plugin that gatters metrics for every keyspace and table, by CrossEngage")
+	configFile     = app.Flag("config", "YAML file whose keys are flag names, providing defaults for the flags").String()
 	checkName      = app.Flag("name", "Check name").Default(appName).String()
-	jolokiaBaseURL = app.Flag("jolokia", "The base URL of the jolokia agent running on Cassandra JVM").Default("http://localhost:1778/jolokia").URL()
-	debug          = app.Flag("debug", "If set, enables debug logs").Default("false").Bool()
+	jolokiaBaseURL = app.Flag("jolokia", "The base URL of the jolokia agent running on Cassandra JVM, can be repeated").Default("http://localhost:1778/jolokia").URLList()
+	user           = app.Flag("user", "User for HTTP basic auth against the jolokia agent").String()
+	password       = app.Flag("password", "Password for HTTP basic auth against the jolokia agent").Envar("JOLOKIA_PASSWORD").String()
+	caCert         = app.Flag("cacert", "PEM file with the CA used to verify the jolokia agent certificate").String()
+	clientCert     = app.Flag("cert", "PEM file with the client certificate presented to the jolokia agent").String()
+	clientKey      = app.Flag("key", "PEM file with the key of the client certificate").String()
+	insecureTLS    = app.Flag("insecure-skip-verify", "If set, does not verify the jolokia agent certificate").Default("false").Bool()
+	timeout        = app.Flag("timeout", "Timeout for the whole request to the jolokia agent").Default("10s").Duration()
+	dialTimeout    = app.Flag("dial-timeout", "Timeout for establishing the TCP connection to the jolokia agent").Default("5s").Duration()
+	retries        = app.Flag("retries", "How many times to retry a failed request to the jolokia agent").Default("0").Int()
+	retryDelay     = app.Flag("retry-delay", "Delay before the first retry, doubled on every following attempt").Default("1s").Duration()
+	bulk           = app.Flag("bulk", "If set, reads only the included metrics, keyspaces and tables with a jolokia bulk request").Default("false").Bool()
+	interval       = app.Flag("interval", "If set, keeps running and scrapes the jolokia agent on this interval").Default("0s").Duration()
+	listen         = app.Flag("listen", "If set, serves prometheus metrics on this address instead of printing them").String()
+	debug          = app.Flag("debug", "If set, enables debug logs, same as --log-level debug").Default("false").Bool()
 	stderr         = app.Flag("stderr", "If set, enables logging to stderr instead of syslog").Default("false").Bool()
+	outputFormat   = app.Flag("output-format", "Output format, either influx, prometheus, graphite, json, opentsdb, statsd or table").Default("influx").Enum(outputFormats...)
 	skipZeros      = app.Flag("skip-zeros", "If set, it will not output metrics that only has zeros").Default("false").Bool()
+	histograms     = app.Flag("histograms", "If set, outputs the p50, p75, p95, p99 and p999 of histogram attributes").Default("false").Bool()
 	skipMetrics    = app.Flag("skip", "CSV with metric names to skip collection").Default(
 		"CasCommitLatency",
 		"CasCommitTotalLatency",
@@ -40,152 +54,491 @@ var (
 		"RowCacheMiss",
 		"SpeculativeRetries",
 	).Strings()
+	skipPatterns        = app.Flag("skip-regex", "Regular expressions matching metric names to skip collection").Strings()
+	includeMetrics      = app.Flag("include", "CSV with the only metric names to collect, takes precedence over --skip").Strings()
+	keyspaces           = app.Flag("keyspace", "CSV with the only keyspaces to collect").Strings()
+	skipKeyspaces       = app.Flag("skip-keyspace", "CSV with keyspaces to skip collection").Strings()
+	skipSystemKeyspaces = app.Flag("skip-system-keyspaces", "If set, skips the keyspaces used internally by Cassandra").Default("false").Bool()
+	tables              = app.Flag("table", "CSV with the only tables to collect").Strings()
+	skipTables          = app.Flag("skip-table", "CSV with tables to skip collection").Strings()
+	defaultTags         = app.Flag("default-tags", "If set, metrics without a keyspace or table get placeholder tags").Default("false").Bool()
+	defaultKeyspace     = app.Flag("default-keyspace", "Placeholder keyspace tag used by --default-tags").Default("_global").String()
+	defaultCF           = app.Flag("default-cf", "Placeholder cf tag used by --default-tags").Default("_none").String()
+	renameFields        = app.Flag("rename", "Renames a field, as old=new, can be repeated").StringMap()
+	mbeanTypes          = app.Flag("mbean-type", "Type of the org.apache.cassandra.metrics MBeans to read, can be repeated").Default(checker.DefaultMBeanType).Strings()
+	measurement         = app.Flag("measurement", "Measurement of the line protocol output, defaults to --name").String()
+	rates               = app.Flag("rates", "If set, adds a per second <field>_rate for Count fields, from the previous scrape in daemon mode").Default("false").Bool()
+	graphitePrefix      = app.Flag("graphite-prefix", "Namespace prepended to the graphite metric paths").Default("cassandra").String()
+	outputFile          = app.Flag("output-file", "If set, atomically replaces this file with the output instead of printing it").String()
+	check               = app.Flag("check", "If set, prints a summary of what would be collected instead of the metrics").Default("false").Bool()
+	syslogFacility      = app.Flag("syslog-facility", "Facility of the logs sent to syslog, such as daemon or local3").Default("daemon").String()
+	syslogSeverity      = app.Flag("syslog-severity", "Severity of the logs sent to syslog, such as notice or info").Default("notice").String()
+	syslogTag           = app.Flag("syslog-tag", "Tag of the logs sent to syslog").Default(appName).String()
+	emitScrapeTiming    = app.Flag("emit-scrape-timing", "If set, adds the time spent in the jolokia HTTP requests to the heartbeat, as jolokia_ms").Default("false").Bool()
+	hostnameFlag        = app.Flag("hostname", "Value of the host tag, defaults to the hostname of the machine").String()
+	staticTags          = app.Flag("tag", "Tag added to every line, as key=value, can be repeated").StringMap()
+	keepNaN             = app.Flag("keep-nan", "If set, keeps NaN and infinite values in the prometheus and graphite output instead of dropping them").Default("false").Bool()
+	scaleFields         = app.Flag("scale", "Multiplies the numeric values of a field, as field=factor, can be repeated").StringMap()
+	nagios              = app.Flag("nagios", "If set, runs as a Nagios plugin checking --check-field against --warn and --critical").Default("false").Bool()
+	checkField          = app.Flag("check-field", "Field compared against the thresholds by --nagios, such as Mean").String()
+	warnThreshold       = app.Flag("warn", "Value above which --nagios reports a warning, required by --nagios").Float64()
+	criticalThreshold   = app.Flag("critical", "Value above which --nagios reports a critical status, required by --nagios").Float64()
+	skipFile            = app.Flag("skip-file", "File with metric names to skip collection, one per line, merged with --skip and re-read on SIGHUP in daemon mode").String()
+	jsonIndent          = app.Flag("json-indent", "If set, pretty prints the series of the json output").Default("false").Bool()
+	numericFields       = app.Flag("numeric-fields", "CSV with fields whose string values are parsed as numbers").Strings()
+	maxResponseBytes    = app.Flag("max-response-bytes", "Size above which a jolokia response is rejected instead of decoded, such as 64MB, 0 for no limit").Default("256MB").Bytes()
+	hostTagKey          = app.Flag("host-tag-key", "Key of the tag holding the hostname").Default("host").String()
+	noHostTag           = app.Flag("no-host-tag", "If set, does not tag the lines with the hostname").Default("false").Bool()
+	workers             = app.Flag("workers", "How many goroutines process the entries of a jolokia response").Default("1").Int()
+	discoverClusterTags = app.Flag("discover-cluster-tags", "If set, tags the lines with the cassandra_version and cluster read from each node at startup").Default("false").Bool()
+	discoverTopology    = app.Flag("discover-topology", "If set, tags the lines with the dc and rack read from the endpoint snitch of each node at startup").Default("false").Bool()
+	onlyChanged         = app.Flag("only-changed", "If set, in daemon mode, leaves out the series unchanged since the previous scrape").Default("false").Bool()
+	heartbeatInterval   = app.Flag("heartbeat-interval", "How often --only-changed emits unchanged series anyway, 0 for never").Default("10m").Duration()
+	failOnEmpty         = app.Flag("fail-on-empty", "If set, fails when a jolokia read matches no metric instead of warning").Default("false").Bool()
+	maxDepth            = app.Flag("max-depth", "If set, the maximum depth of the values returned by jolokia").Default("0").Int()
+	maxCollectionSize   = app.Flag("max-collection-size", "If set, the maximum number of items of the collections returned by jolokia").Default("0").Int()
+	maxObjects          = app.Flag("max-objects", "If set, the maximum number of objects returned by jolokia").Default("0").Int()
+	statsdAddr          = app.Flag("statsd-addr", "If set, sends the statsd output to this DogStatsD host:port over UDP instead of printing it").String()
+	promHistograms      = app.Flag("prometheus-histograms", "If set, the prometheus output exports histogram attributes as native histograms").Default("false").Bool()
+	maxIdleConns        = app.Flag("max-idle-conns", "How many idle connections to keep for reuse per jolokia agent").Default("4").Int()
+	idleConnTimeout     = app.Flag("idle-conn-timeout", "How long to keep idle connections to the jolokia agents; should exceed --interval to reuse them across scrapes").Default("5m").Duration()
+	flattenComposite    = app.Flag("flatten-composite", "If set, flattens composite attribute values into dotted field names, such as GCStats.CollectionCount").Default("false").Bool()
+	logLevel            = app.Flag("log-level", "Minimum level of the logs, either error, warn, info or debug. At info, every scrape logs a summary").Default("warn").Enum("error", "warn", "info", "debug")
+	quiet               = app.Flag("quiet", "If set, only logs errors, overriding --log-level and --debug").Default("false").Bool()
+	socketAddr          = app.Flag("socket", "If set, writes the output to this unix or tcp socket, such as unix:///tmp/telegraf.sock, instead of printing it").String()
+	warnDuplicates      = app.Flag("warn-duplicates", "If set, warns about MBeans mapping to the same series, which are merged into one line").Default("false").Bool()
+	fields              = app.Flag("fields", "The only attributes to collect, such as Count or Mean, repeatable").Strings()
+	skipFields          = app.Flag("skip-fields", "Attributes to skip collection, such as FiveMinuteRate, repeatable").Strings()
+	probe               = app.Flag("probe", "If set, logs the version and limits of each jolokia agent at startup").Default("false").Bool()
+	sampleRate          = app.Flag("sample-rate", "Fraction of the series to emit, from 0 to 1, always choosing the same ones").Default("1").Float64()
+	execOperation       = app.Flag("exec", "If set, invokes this MBean operation, as <mbean>/<operation>[/args], prints its result and exits instead of collecting metrics").String()
+	timestampSource     = app.Flag("timestamp-source", "Timestamp of the lines, either jolokia, local for the local clock, or a fixed RFC3339 time for backfills").Default("jolokia").String()
+	breakerThreshold    = app.Flag("breaker-threshold", "In daemon mode, how many consecutive failed scrapes back off the interval, 0 disabling it").Default("3").Int()
+	breakerMaxInterval  = app.Flag("breaker-max-interval", "In daemon mode, the longest interval the failed scrapes back off to").Default("5m").Duration()
+	rollupKeyspace      = app.Flag("rollup-keyspace", "If set, also emits the fields of the tables rolled up per keyspace, tagged cf=_total").Default("false").Bool()
+	rollupOnly          = app.Flag("rollup-only", "If set, only emits the keyspace rollups instead of the tables they roll up, implies --rollup-keyspace").Default("false").Bool()
+	rollupFields        = app.Flag("rollup", "Rolls up only the given field with a function, either sum, avg, min or max, as field=function, can be repeated").StringMap()
+	warnUnknownSkips    = app.Flag("warn-unknown-skips", "If set, warns about --skip and --include names matching no metric, and fails --check. Not checked with --bulk").Default("false").Bool()
+	noIntegerSuffix     = app.Flag("no-integer-suffix", "If set, the influx output writes integers without the i suffix, for consumers expecting only floats").Default("false").Bool()
+	targetsFile         = app.Flag("targets-file", "YAML or JSON file listing the jolokia agents to scrape instead of --jolokia, as url and optional tags, re-read on every scrape").String()
+	boolAsInt           = app.Flag("bool-as-int", "If set, boolean attributes are emitted as 1 or 0 instead of true or false").Default("false").Bool()
+	requestID           = app.Flag("request-id", "X-Request-ID header sent to jolokia, a random UUID per scrape by default").String()
+	emitDeltas          = app.Flag("emit-deltas", "Adds a <field>_delta with the change of the field since the previous scrape in daemon mode, can be repeated").Strings()
+	latencyOnly         = app.Flag("latency-only", "If set, only collects the percentiles of ReadLatency, WriteLatency and RangeLatency, unless --include or --fields are given").Default("false").Bool()
+	dropEmptyTags       = app.Flag("drop-empty-tags", "Leaves out the tags whose value is empty, such as the cf of scope=, use --no-drop-empty-tags to keep them").Default("true").Bool()
+	strict              = app.Flag("strict", "If set, fails the scrape on values that would be skipped as invalid, such as NaN or an unexpected type").Default("false").Bool()
+	flushLines          = app.Flag("flush-lines", "If set, flushes the output every this many lines instead of once per scrape").Default("0").Int()
+	flushInterval       = app.Flag("flush-interval", "If set, flushes the output at least this often while writing a scrape").Default("0s").Duration()
+	maxSeries           = app.Flag("max-series", "If set, the most series emitted per scrape, the others being dropped with a warning").Default("0").Int()
+	targetURL           = app.Flag("target-url", "If set, uses jolokia as a proxy reading this JMX service URL, such as service:jmx:rmi:///jndi/rmi://cassandra:7199/jmxrmi").String()
+	targetUser          = app.Flag("target-user", "User for the JMX target of the jolokia proxy").String()
+	targetPassword      = app.Flag("target-password", "Password for the JMX target of the jolokia proxy").String()
+	timestampPrecision  = app.Flag("timestamp-precision", "Precision of the influx timestamps, matching the one the consumer accepts").Default("ns").Enum("ns", "us", "ms", "s")
+	stripMetricPrefix   = app.Flag("strip-metric-prefix", "Prefix removed from the metric tag of the names starting with it").String()
+	outputSpecs         = app.Flag("output", "Output as format[:destination], with - for stdout, unix:// or tcp:// for a socket, host:port for statsd or else a file. Can be repeated to write several outputs from the same scrape").PlaceHolder("FORMAT[:DEST]").Strings()
+	activeOnly          = app.Flag("active-only", "If set, leaves out the metrics of the tables without reads nor writes since the previous scrape, from the Count of ReadLatency and WriteLatency").Default("false").Bool()
+	emitMBeanTag        = app.Flag("emit-mbean-tag", "If set, adds the full MBean name of each series as the mbean tag, for debugging. Increases the cardinality").Default("false").Bool()
+	intervalJitter      = app.Flag("interval-jitter", "Randomizes the first daemon scrape within this fraction of --interval, such as 0.1, or duration, to spread the scrapes of a fleet").Default("0").String()
+	jitterEach          = app.Flag("jitter-each-interval", "If set, --interval-jitter also moves every following scrape by up to half the jitter either way").Default("false").Bool()
+	shutdownTimeout     = app.Flag("shutdown-timeout", "On SIGINT or SIGTERM, how long the scrape or HTTP request in progress gets to finish writing its output").Default("10s").Duration()
+	compactFloats       = app.Flag("compact-floats", "If set, the influx output writes floats in their shortest exact form, such as 1.5, instead of with six decimals").Default("false").Bool()
+	healthAddr          = app.Flag("health-addr", "If set, serves the /healthz and /ready probes on this address in daemon mode, ready after the first successful scrape. --listen serves them itself").String()
+	listMetrics         = app.Flag("list-metrics", "If set, prints the metric names available on the jolokia agents instead of the metrics").Default("false").Bool()
+	logFormat           = app.Flag("log-format", "Log format, either text or json").Default("text").Enum("text", "json")
+	concurrency         = app.Flag("concurrency", "How many jolokia agents to scrape at the same time").Default("4").Int()
+	minValue            = app.Flag("min-value", "If set, it will not output metrics whose numeric fields are all below this absolute value").Default("0").Float64()
+	emitTypeTags        = app.Flag("emit-type-tags", "If set, tags well known metrics with their metric_type and unit in the influx output").Default("false").Bool()
+	proxy               = app.Flag("proxy", "URL of the HTTP proxy used to reach jolokia, overriding HTTP_PROXY and friends").URL()
 )
 
 func main() {
 	app.Version(version)
-	kingpin.MustParse(app.Parse(os.Args[1:]))
+	recordFlagSources(os.Args[1:])
+	if filename := configFileFromArgs(os.Args[1:]); filename != "" {
+		if err := loadConfigFile(filename); err != nil {
+			fatal(exitConfig, "Could not load the config file", "error", err)
+		}
+	}
+	if _, err := app.Parse(os.Args[1:]); err != nil {
+		fatal(exitConfig, "Invalid arguments, try --help", "error", err)
+	}
 
-	if *debug {
-		log.SetFlags(log.LstdFlags | log.Lshortfile)
+	if err := setupLogging(); err != nil {
+		fatal(exitFailure, "Could not set up logging", "error", err)
 	}
 
-	if *stderr {
-		log.SetOutput(os.Stderr)
-	} else {
-		slog, err := syslog.New(syslog.LOG_NOTICE|syslog.LOG_DAEMON, appName)
-		if err != nil {
-			log.Fatal(err)
+	hostname := resolveHostname(*hostnameFlag, os.Hostname)
+
+	for key, value := range *staticTags {
+		if key == "" || value == "" || (key == *hostTagKey && !*noHostTag) {
+			fatal(exitConfig, "Invalid --tag, expected key=value with a key other than --host-tag-key", "tag", key+"="+value)
 		}
-		log.SetOutput(slog)
+	}
 
+	if *socketAddr != "" && (*statsdAddr != "" || *outputFile != "") {
+		fatal(exitConfig, "--socket cannot be used with --statsd-addr or --output-file")
+	}
+	if len(*outputSpecs) > 0 && (*socketAddr != "" || *statsdAddr != "" || *outputFile != "") {
+		fatal(exitConfig, "--output cannot be used with --socket, --statsd-addr or --output-file")
+	}
+	opts := outputOptions{flushLines: *flushLines, flushInterval: *flushInterval, dialTimeout: *dialTimeout, writeTimeout: *timeout}
+	sinks := []sink{}
+	for _, spec := range *outputSpecs {
+		s, err := parseSink(spec, opts)
+		if err != nil {
+			fatal(exitConfig, "Invalid --output", "output", spec, "error", err)
+		}
+		sinks = append(sinks, s)
 	}
+	if len(sinks) == 0 {
+		s, err := defaultSink(*outputFormat, *socketAddr, *statsdAddr, *outputFile, opts)
+		if err != nil {
+			fatal(exitConfig, "Invalid --socket", "error", err)
+		}
+		sinks = append(sinks, s)
+	}
+	formats := sinkFormats(sinks)
+	for key, fn := range *rollupFields {
+		switch fn {
+		case "sum", "avg", "min", "max":
+		default:
+			fatal(exitConfig, "Invalid --rollup, expected field=sum, avg, min or max", "rollup", key+"="+fn)
+		}
+	}
+	jitterMax, err := parseJitter(*intervalJitter, *interval)
+	if err != nil {
+		fatal(exitConfig, "Invalid --interval-jitter", "error", err)
+	}
+	if *sampleRate <= 0 || *sampleRate > 1 {
+		fatal(exitConfig, "--sample-rate must be above 0 and at most 1", "sample_rate", *sampleRate)
+	}
+	if *statsdAddr != "" && *outputFormat != "statsd" {
+		fatal(exitConfig, "--statsd-addr requires --output-format statsd")
+	}
+
+	include, keepFields := latencyPreset(*latencyOnly, splitCSV(*includeMetrics), *fields)
 
-	hostname, err := os.Hostname()
+	skipRegex, err := compileSkipRegex(*skipPatterns)
 	if err != nil {
-		log.Fatal(err)
+		fatal(exitConfig, "Invalid --skip-regex", "error", err)
 	}
 
-	keys := []string{*checkName, "host=" + hostname}
+	var fixedTimestamp time.Time
+	if *timestampSource != "jolokia" && *timestampSource != "local" {
+		if fixedTimestamp, err = time.Parse(time.RFC3339, *timestampSource); err != nil {
+			fatal(exitConfig, "Invalid --timestamp-source, expected jolokia, local or an RFC3339 time", "error", err)
+		}
+	}
+
+	scale := map[string]float64{}
+	for key, value := range *scaleFields {
+		factor, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			fatal(exitConfig, "Invalid --scale, expected field=factor", "scale", key+"="+value, "error", err)
+		}
+		scale[key] = factor
+	}
 
-	loc, err := url.Parse((*jolokiaBaseURL).String() + "/read/org.apache.cassandra.metrics:type=ColumnFamily,keyspace=*,scope=*,name=*")
+	skip, err := skipList(splitCSV(*skipMetrics), *skipFile)
 	if err != nil {
-		log.Fatal(err)
+		fatal(exitConfig, "Could not read --skip-file", "error", err)
+	}
+
+	cfg := checker.Config{
+		Name:                 *checkName,
+		Measurement:          *measurement,
+		Hostname:             hostname,
+		HostTagKey:           *hostTagKey,
+		NoHostTag:            *noHostTag,
+		Tags:                 *staticTags,
+		JolokiaURLs:          *jolokiaBaseURL,
+		Concurrency:          *concurrency,
+		Workers:              *workers,
+		Proxy:                *proxy,
+		RequestID:            *requestID,
+		User:                 *user,
+		Password:             *password,
+		TargetURL:            *targetURL,
+		TargetUser:           *targetUser,
+		TargetPassword:       *targetPassword,
+		CACert:               *caCert,
+		Cert:                 *clientCert,
+		Key:                  *clientKey,
+		InsecureSkipVerify:   *insecureTLS,
+		Timeout:              *timeout,
+		DialTimeout:          *dialTimeout,
+		MaxIdleConnsPerHost:  *maxIdleConns,
+		IdleConnTimeout:      *idleConnTimeout,
+		Retries:              *retries,
+		RetryDelay:           *retryDelay,
+		Bulk:                 *bulk,
+		MBeanTypes:           *mbeanTypes,
+		MaxResponseBytes:     int64(*maxResponseBytes),
+		MaxDepth:             *maxDepth,
+		MaxCollectionSize:    *maxCollectionSize,
+		MaxObjects:           *maxObjects,
+		MaxSeries:            *maxSeries,
+		FailOnEmpty:          *failOnEmpty,
+		OutputFormat:         *outputFormat,
+		GraphitePrefix:       *graphitePrefix,
+		JSONIndent:           *jsonIndent,
+		NoIntegerSuffix:      *noIntegerSuffix,
+		CompactFloats:        *compactFloats,
+		TimestampPrecision:   *timestampPrecision,
+		StripMetricPrefix:    *stripMetricPrefix,
+		EmitMBeanTag:         *emitMBeanTag,
+		SkipZeros:            *skipZeros,
+		MinValue:             *minValue,
+		KeepNaN:              *keepNaN,
+		BoolAsInt:            *boolAsInt,
+		Strict:               *strict,
+		Histograms:           *histograms,
+		PrometheusHistograms: *promHistograms && hasFormat(formats, "prometheus"),
+		FlattenComposite:     *flattenComposite,
+		WarnDuplicates:       *warnDuplicates,
+		RollupKeyspace:       *rollupKeyspace || *rollupOnly,
+		RollupOnly:           *rollupOnly,
+		Rollup:               *rollupFields,
+		EmitTypeTags:         *emitTypeTags,
+		EmitScrapeTiming:     *emitScrapeTiming,
+		TimestampSource:      *timestampSource,
+		FixedTimestamp:       fixedTimestamp,
+		Rename:               *renameFields,
+		Scale:                scale,
+		NumericFields:        splitCSV(*numericFields),
+		DefaultTags:          *defaultTags,
+		DropEmptyTags:        *dropEmptyTags,
+		DefaultKeyspace:      *defaultKeyspace,
+		DefaultCF:            *defaultCF,
+		Skip:                 skip,
+		Include:              include,
+		SkipRegex:            skipRegex,
+		WarnUnknownSkips:     *warnUnknownSkips,
+		Keyspaces:            splitCSV(*keyspaces),
+		SkipKeyspaces:        splitCSV(*skipKeyspaces),
+		SkipSystemKeyspaces:  *skipSystemKeyspaces,
+		Tables:               splitCSV(*tables),
+		SkipTables:           splitCSV(*skipTables),
+		Fields:               keepFields,
+		SkipFields:           *skipFields,
+		SampleRate:           *sampleRate,
 	}
 
-	// TODO timeouts
-	tr := &http.Transport{}
-	client := &http.Client{Transport: tr}
-	resp, err := client.Get(loc.String())
+	if *rates {
+		cfg.Rates = checker.NewRates()
+	}
+	if *activeOnly {
+		cfg.Activity = checker.NewActivity()
+	}
+	if len(*emitDeltas) > 0 {
+		cfg.Deltas = checker.NewDeltas(*emitDeltas)
+	}
+	if *onlyChanged && *interval > 0 {
+		cfg.Changes = checker.NewChanges(*heartbeatInterval)
+	}
+
+	client, err := checker.NewClient(cfg)
 	if err != nil {
-		log.Fatal(err)
+		fatal(exitConfig, "Could not create the HTTP client", "error", err)
 	}
-	defer resp.Body.Close()
-	if resp.StatusCode != 200 {
-		log.Fatalf("%s %s", loc, resp.Status)
+
+	var targets *targetSet
+	if *targetsFile != "" {
+		targets = &targetSet{filename: *targetsFile}
+		if err := targets.reload(); err != nil {
+			fatal(exitConfig, "Could not read the targets file", "error", err)
+		}
+		cfg = targets.apply(cfg)
 	}
 
-	jsonResp := &jsonResp{}
-	if err := json.NewDecoder(resp.Body).Decode(jsonResp); err != nil {
-		log.Fatal(err)
+	if *probe {
+		probeAgents(client, cfg)
+	}
+	if *discoverClusterTags {
+		cfg.NodeTags = checker.DiscoverClusterTags(context.Background(), client, cfg)
+	}
+	if *discoverTopology {
+		cfg.NodeTags = mergeNodeTags(cfg.NodeTags, checker.DiscoverTopologyTags(context.Background(), client, cfg))
+	}
+	discoveredTags := cfg.NodeTags
+	if targets != nil {
+		cfg = targets.apply(cfg)
 	}
 
-	if jsonResp.Status != 200 || jsonResp.Error != nil {
-		log.Fatal(jsonResp.Error)
+	if *execOperation != "" {
+		runExec(client, cfg)
+		return
+	}
+	if *check {
+		runCheck(client, cfg)
+		return
 	}
 
-	timestamp := time.Unix(jsonResp.TimeStamp, 0)
-	commonKey := strings.Join(keys, ",")
+	if *nagios {
+		runNagios(client, cfg)
+	}
 
-	for keyPath, valueMap := range jsonResp.Value {
-		keyPath = strings.Replace(keyPath, "org.apache.cassandra.metrics:", "", 1)
-		if skipMetric(keyPath) {
-			continue
+	if *listMetrics {
+		runListMetrics(client, cfg)
+		return
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	if *listen != "" {
+		if err := serveMetrics(ctx, *listen, client, cfg, *shutdownTimeout); err != nil {
+			fatal(exitFailure, "HTTP server failed", "error", err)
 		}
+		return
+	}
 
-		keyParts := strings.Split(keyPath, ",")
-		tags := []string{}
-		for _, part := range keyParts {
-			kv := strings.Split(part, "=")
-			switch kv[0] {
-			case "keyspace":
-				tags = append(tags, "keyspace="+kv[1])
-			case "name":
-				tags = append(tags, "metric="+kv[1])
-			case "scope":
-				tags = append(tags, "cf="+kv[1])
-			}
+	live := &liveConfig{cfg: cfg}
+	scrape := func(ctx context.Context) error {
+		cfg := live.get()
+		if targets != nil {
+			// The targets file is re-read on every scrape, so nodes come
+			// and go without a restart.
+			targets.refresh()
+			cfg.NodeTags = discoveredTags
+			cfg = targets.apply(cfg)
 		}
+		outputs, err := checker.ScrapeFormats(ctx, client, cfg, formats)
+		if err != nil {
+			writeOutputs(sinks, outputs, true)
+			return err
+		}
+		scraped.Store(true)
+		return writeOutputs(sinks, outputs, false)
+	}
 
-		values := []string{}
-		zeroValuesCount := 0
-		numericValues := 0
-		for valueKey, value := range valueMap {
-			if value == nil {
-				continue
-			}
-			rt := reflect.TypeOf(value)
-			if rt.Kind() == reflect.Slice {
-				continue
-			}
-			switch v := value.(type) {
-			case int64, int32, int16, int8, int, uint64, uint32, uint16, uint8, uint:
-				values = append(values, fmt.Sprintf(`%s=%di`, valueKey, v))
-				numericValues++
-				if v == 0 {
-					zeroValuesCount++
-				}
-			case float32, float64, complex64, complex128:
-				values = append(values, fmt.Sprintf(`%s=%f`, valueKey, v))
-				numericValues++
-				if v == 0.0 {
-					zeroValuesCount++
-				}
-			case string:
-				values = append(values, fmt.Sprintf(`%s="%s"`, valueKey, v))
-			}
+	if *interval == 0 {
+		work, cancel := gracefulContext(ctx, *shutdownTimeout)
+		err := scrape(work)
+		cancel()
+		if err != nil {
+			fatal(exitCode(err), "Scrape failed", "error", err)
+		}
+		return
+	}
+
+	reload := func() {
+		cfg, err := live.reloadFilters()
+		if err != nil {
+			slog.Error("Could not reload the filters, keeping the previous ones", "error", err)
+			return
+		}
+		slog.Info("Reloaded the filters", "skip", len(cfg.Skip), "skip_regex", len(cfg.SkipRegex),
+			"include", len(cfg.Include), "keyspaces", len(cfg.Keyspaces), "skip_keyspaces", len(cfg.SkipKeyspaces),
+			"tables", len(cfg.Tables), "skip_tables", len(cfg.SkipTables))
+	}
+
+	if *healthAddr != "" {
+		if err := serveHealth(*healthAddr); err != nil {
+			fatal(exitConfig, "Could not serve the health probes", "error", err)
 		}
+	}
+
+	b := &breaker{threshold: *breakerThreshold, maxInterval: *breakerMaxInterval}
+	runDaemon(ctx, *interval, *shutdownTimeout, b, newJitter(jitterMax, *jitterEach, hostname), scrape, reload)
+}
+
+// unknownHostname is the host tag when the hostname cannot be looked up.
+const unknownHostname = "unknown"
+
+// resolveHostname returns override when set, otherwise the hostname given
+// by lookup. A failed lookup only warns, as the host tag is not essential.
+func resolveHostname(override string, lookup func() (string, error)) string {
+	if override != "" {
+		return override
+	}
+	hostname, err := lookup()
+	if err != nil || hostname == "" {
+		slog.Warn("Could not get the hostname, using a placeholder", "hostname", unknownHostname, "error", err)
+		return unknownHostname
+	}
+	return hostname
+}
 
-		if *skipZeros && (zeroValuesCount == numericValues) {
-			if *debug {
-				log.Printf("Skipping `%s` because it has %d zero values of %d numeric values",
-					keyPath, zeroValuesCount, numericValues)
+// runDaemon calls scrape every interval until ctx is cancelled. A scrape in
+// progress then gets up to shutdownTimeout to finish writing. A failed
+// scrape is logged and the next one is attempted as usual, unless the
+// failures open the breaker, which backs off the interval. The jitter delays
+// the first scrape and, optionally, moves the following ones. Between
+// scrapes, SIGHUP calls reload.
+func runDaemon(ctx context.Context, interval, shutdownTimeout time.Duration, b *breaker, j *jitter, scrape func(context.Context) error, reload func()) {
+	timer := time.NewTimer(j.first())
+	defer timer.Stop()
+
+	work, cancel := gracefulContext(ctx, shutdownTimeout)
+	defer cancel()
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	for {
+	wait:
+		for {
+			select {
+			case <-ctx.Done():
+				slog.Info("Received signal, exiting")
+				return
+			case <-hup:
+				reload()
+			case <-timer.C:
+				break wait
 			}
-			continue
 		}
 
-		if len(values) > 0 {
-			fmt.Print(commonKey, ",", strings.Join(tags, ","))
-			fmt.Print(" ")
-			fmt.Print(strings.Join(values, ","))
-			fmt.Print(" ")
-			fmt.Println(timestamp.UnixNano())
+		start := time.Now()
+		err := scrape(work)
+		if ctx.Err() != nil {
+			continue
 		}
+		// The time spent scraping counts toward the interval, so the
+		// scrapes keep their pace.
+		delay := j.next(b.next(interval, err)) - time.Since(start)
+		if delay < 0 {
+			delay = 0
+		}
+		timer.Reset(delay)
 	}
 }
 
-type jsonResp struct {
-	Request struct {
-		MBean string `json:"mbean"`
-		Type  string `json:"type"`
-	} `json:"request"`
-	Status     int                               `json:"status"`
-	Error      error                             `json:"error"`
-	ErrorType  string                            `json:"error_type"`
-	StackTrace string                            `json:"stacktrace"`
-	TimeStamp  int64                             `json:"timestamp"`
-	Value      map[string]map[string]interface{} `json:"value"`
+// gracefulContext returns a context for the work in progress, cancelled
+// timeout after ctx is, so a scrape interrupted by a signal can still finish
+// writing its output instead of leaving a torn last line.
+func gracefulContext(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	work, cancel := context.WithCancel(context.Background())
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-work.Done():
+			return
+		}
+		select {
+		case <-time.After(timeout):
+			slog.Warn("Shutdown timeout reached, aborting the scrape in progress", "timeout", timeout)
+			cancel()
+		case <-work.Done():
+		}
+	}()
+	return work, cancel
 }
 
-func skipMetric(keyPath string) bool {
-	for _, metricToSkip := range *skipMetrics {
-		part := ",name=" + metricToSkip + ","
-		if strings.Contains(keyPath, part) {
-			if *debug {
-				log.Printf("Skipping `%s` because it matches `%s`", keyPath, part)
+// mergeNodeTags adds the tags of extra to those of nodeTags, by agent URL.
+func mergeNodeTags(nodeTags, extra map[string]map[string]string) map[string]map[string]string {
+	merged := map[string]map[string]string{}
+	for _, from := range []map[string]map[string]string{nodeTags, extra} {
+		for node, tags := range from {
+			if merged[node] == nil {
+				merged[node] = map[string]string{}
+			}
+			for key, value := range tags {
+				merged[node][key] = value
 			}
-			return true
 		}
 	}
-	return false
+	return merged
 }
@@ -0,0 +1,565 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// TestMain runs main instead of the tests when re-executed by runMain.
+func TestMain(m *testing.M) {
+	if os.Getenv("CHECKER_RUN_MAIN") == "1" {
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// runMain runs the checker with args in a new process, returning its exit
+// code and what it wrote.
+func runMain(t *testing.T, args ...string) (int, string, string) {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], args...)
+	cmd.Env = append(os.Environ(), "CHECKER_RUN_MAIN=1")
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode(), stdout.String(), stderr.String()
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return 0, stdout.String(), stderr.String()
+}
+
+// startMain starts the checker with args in a new process, such as a
+// daemon, killing it at the end of the test. Its output is written to the
+// given buffers, to read once the process exited.
+func startMain(t *testing.T, stdout, stderr *bytes.Buffer, args ...string) *exec.Cmd {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], args...)
+	cmd.Env = append(os.Environ(), "CHECKER_RUN_MAIN=1")
+	cmd.Stdout, cmd.Stderr = stdout, stderr
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+	return cmd
+}
+
+// tableMetrics are the table metrics served by stubJolokia.
+var tableMetrics = []struct{ keyspace, table, name string }{
+	{"app", "users", "ReadLatency"},
+	{"app", "users", "WriteLatency"},
+	{"app", "users", "LiveDiskSpaceUsed"},
+	{"app", "events", "ReadLatency"},
+	{"logs", "events", "ReadLatency"},
+	{"system", "local", "WriteLatency"},
+}
+
+// stubJolokia runs a jolokia agent serving tableMetrics, returning its URL.
+func stubJolokia(t *testing.T) string {
+	t.Helper()
+	values := []string{}
+	for _, m := range tableMetrics {
+		mbean := fmt.Sprintf("org.apache.cassandra.metrics:keyspace=%s,name=%s,scope=%s,type=ColumnFamily", m.keyspace, m.name, m.table)
+		values = append(values, fmt.Sprintf(`%q: {"Count": 1}`, mbean))
+	}
+	body := `{"status": 200, "timestamp": 1700000000, "value": {` + strings.Join(values, ",") + `}}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, body)
+	}))
+	t.Cleanup(server.Close)
+	return server.URL + "/jolokia"
+}
+
+// collected returns the keyspace/table/metric of every influx line of the
+// table metrics in stdout, sorted.
+func collected(stdout string) []string {
+	got := []string{}
+	for _, line := range strings.Split(stdout, "\n") {
+		if !strings.HasPrefix(line, "ckc,") {
+			continue
+		}
+		tags := map[string]string{}
+		series, _, _ := strings.Cut(line, " ")
+		for _, tag := range strings.Split(series, ",")[1:] {
+			key, value, _ := strings.Cut(tag, "=")
+			tags[key] = value
+		}
+		got = append(got, tags["keyspace"]+"/"+tags["cf"]+"/"+tags["metric"])
+	}
+	sort.Strings(got)
+	return got
+}
+
+// runFilters runs a scrape of stubJolokia with args, returning what it
+// collected.
+func runFilters(t *testing.T, args ...string) []string {
+	t.Helper()
+	args = append([]string{"--stderr", "--measurement", "ckc", "--jolokia", stubJolokia(t)}, args...)
+	code, stdout, stderr := runMain(t, args...)
+	if code != 0 {
+		t.Fatalf("exit code = %d, want 0; stderr: %s", code, stderr)
+	}
+	return collected(stdout)
+}
+
+func TestIncludeFlag(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want []string
+	}{
+		{"csv", []string{"--include", "WriteLatency,LiveDiskSpaceUsed"}, []string{"app/users/LiveDiskSpaceUsed", "app/users/WriteLatency", "system/local/WriteLatency"}},
+		{"repeated", []string{"--include", "WriteLatency", "--include", "LiveDiskSpaceUsed"}, []string{"app/users/LiveDiskSpaceUsed", "app/users/WriteLatency", "system/local/WriteLatency"}},
+		{"csv with spaces", []string{"--include", "LiveDiskSpaceUsed, WriteLatency"}, []string{"app/users/LiveDiskSpaceUsed", "app/users/WriteLatency", "system/local/WriteLatency"}},
+		{"over skip", []string{"--include", "LiveDiskSpaceUsed", "--skip", "LiveDiskSpaceUsed,ReadLatency"}, []string{"app/users/LiveDiskSpaceUsed"}},
+		{"skip csv", []string{"--skip", "ReadLatency,WriteLatency"}, []string{"app/users/LiveDiskSpaceUsed"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := runFilters(t, tt.args...); strings.Join(got, " ") != strings.Join(tt.want, " ") {
+				t.Errorf("collected %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKeyspaceFlags(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want []string
+	}{
+		{"keyspace csv", []string{"--keyspace", "logs,system"}, []string{"logs/events/ReadLatency", "system/local/WriteLatency"}},
+		{"skip-keyspace csv", []string{"--skip-keyspace", "app, logs"}, []string{"system/local/WriteLatency"}},
+		{"system keyspaces", []string{"--skip-system-keyspaces", "--skip-keyspace", "app"}, []string{"logs/events/ReadLatency"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := runFilters(t, tt.args...); strings.Join(got, " ") != strings.Join(tt.want, " ") {
+				t.Errorf("collected %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTableFlags(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want []string
+	}{
+		{"table csv", []string{"--table", "events,local"}, []string{"app/events/ReadLatency", "logs/events/ReadLatency", "system/local/WriteLatency"}},
+		{"skip-table csv", []string{"--skip-table", "events, local"}, []string{"app/users/LiveDiskSpaceUsed", "app/users/ReadLatency", "app/users/WriteLatency"}},
+		{"keyspace and table", []string{"--keyspace", "app,system", "--table", "events,local"}, []string{"app/events/ReadLatency", "system/local/WriteLatency"}},
+		{"keyspace, table and skip", []string{"--keyspace", "app", "--table", "users", "--skip", "ReadLatency,WriteLatency"}, []string{"app/users/LiveDiskSpaceUsed"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := runFilters(t, tt.args...); strings.Join(got, " ") != strings.Join(tt.want, " ") {
+				t.Errorf("collected %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFieldFlags(t *testing.T) {
+	all := []string{"app/events/ReadLatency", "app/users/LiveDiskSpaceUsed", "app/users/ReadLatency", "app/users/WriteLatency", "logs/events/ReadLatency", "system/local/WriteLatency"}
+	tests := []struct {
+		name string
+		args []string
+		want []string
+	}{
+		{"fields", []string{"--fields", "Mean", "--fields", "Count"}, all},
+		{"fields without count", []string{"--fields", "Mean"}, []string{}},
+		{"skip-fields", []string{"--skip-fields", "Count"}, []string{}},
+		{"skip-fields of others", []string{"--skip-fields", "Mean"}, all},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := runFilters(t, tt.args...); strings.Join(got, " ") != strings.Join(tt.want, " ") {
+				t.Errorf("collected %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// Sampling hashes the series, so separate runs keep the same ones.
+func TestSampleRateFlag(t *testing.T) {
+	first := runFilters(t, "--sample-rate", "0.5")
+	if len(first) == 0 || len(first) == len(tableMetrics) {
+		t.Errorf("collected %q, want only some of the series", first)
+	}
+	for i := 0; i < 3; i++ {
+		if got := runFilters(t, "--sample-rate", "0.5"); strings.Join(got, " ") != strings.Join(first, " ") {
+			t.Errorf("run %d collected %q, want %q as the first run", i+2, got, first)
+		}
+	}
+}
+
+func TestInvalidArguments(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{"unknown flag", []string{"--bogus"}},
+		{"invalid duration", []string{"--timeout", "abc"}},
+		{"invalid enum", []string{"--output-format", "xml"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if code, _, stderr := runMain(t, tt.args...); code != exitConfig {
+				t.Errorf("exit code = %d, want %d; stderr: %s", code, exitConfig, stderr)
+			}
+		})
+	}
+}
+
+func TestListMetrics(t *testing.T) {
+	code, stdout, stderr := runMain(t, "--stderr", "--list-metrics", "--jolokia", stubJolokia(t))
+	if code != 0 {
+		t.Fatalf("exit code = %d, want 0; stderr: %s", code, stderr)
+	}
+	want := "ColumnFamily (3 metrics):\n  LiveDiskSpaceUsed\n  ReadLatency\n  WriteLatency\n"
+	if stdout != want {
+		t.Errorf("stdout = %q, want %q", stdout, want)
+	}
+}
+
+func TestStaticTags(t *testing.T) {
+	code, stdout, stderr := runMain(t, "--stderr", "--jolokia", stubJolokia(t), "--hostname", "cassandra-1", "--tag", "dc=eu1", "--tag", "cluster=main")
+	if code != 0 {
+		t.Fatalf("exit code = %d, want 0; stderr: %s", code, stderr)
+	}
+	lines := strings.Split(strings.TrimSpace(stdout), "\n")
+	if len(lines) != len(tableMetrics)+1 {
+		t.Fatalf("stdout = %q, want a line per metric and the heartbeat", stdout)
+	}
+	for _, line := range lines {
+		series, _, _ := strings.Cut(line, " ")
+		for _, tag := range []string{",cluster=main", ",dc=eu1", ",host=cassandra-1"} {
+			if !strings.Contains(series+",", tag+",") {
+				t.Errorf("line %q is missing the tag %s", line, tag[1:])
+			}
+		}
+	}
+}
+
+func TestDiscoverTopologyFlag(t *testing.T) {
+	metrics := stubJolokia(t)
+	tests := []struct {
+		name   string
+		snitch string
+		want   []string
+	}{
+		{"snitch", `{"status": 200, "timestamp": 1, "value": {"Datacenter": "eu-west", "Rack": "rack1"}}`, []string{",dc=eu-west", ",rack=rack1"}},
+		{"no snitch", `{"status": 404, "error_type": "javax.management.InstanceNotFoundException", "error": "not found"}`, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The agent answers the snitch read itself and forwards the
+			// scrapes to the metrics agent.
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == "/jolokia/read/org.apache.cassandra.db:type=EndpointSnitchInfo/Datacenter,Rack" {
+					io.WriteString(w, tt.snitch)
+					return
+				}
+				resp, err := http.Post(metrics, "application/json", r.Body)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				defer resp.Body.Close()
+				io.Copy(w, resp.Body)
+			}))
+			t.Cleanup(server.Close)
+
+			code, stdout, stderr := runMain(t, "--stderr", "--measurement", "ckc", "--jolokia", server.URL+"/jolokia", "--discover-topology")
+			if code != 0 {
+				t.Fatalf("exit code = %d, want 0; stderr: %s", code, stderr)
+			}
+			if got := collected(stdout); len(got) != len(tableMetrics) {
+				t.Fatalf("stdout = %q, want a line per metric", stdout)
+			}
+			for _, line := range strings.Split(stdout, "\n") {
+				if !strings.HasPrefix(line, "ckc,") {
+					continue
+				}
+				series, _, _ := strings.Cut(line, " ")
+				for _, tag := range tt.want {
+					if !strings.Contains(series+",", tag+",") {
+						t.Errorf("line %q is missing the tag %s", line, tag[1:])
+					}
+				}
+				if tt.want == nil && (strings.Contains(series, ",dc=") || strings.Contains(series, ",rack=")) {
+					t.Errorf("line %q has topology tags without a snitch", line)
+				}
+			}
+		})
+	}
+}
+
+func TestInvalidStaticTags(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{"without value", []string{"--tag", "dc="}},
+		{"without key", []string{"--tag", "=eu1"}},
+		{"without equals", []string{"--tag", "dc"}},
+		{"host tag", []string{"--tag", "host=other"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if code, _, stderr := runMain(t, append([]string{"--stderr"}, tt.args...)...); code != exitConfig {
+				t.Errorf("exit code = %d, want %d; stderr: %s", code, exitConfig, stderr)
+			}
+		})
+	}
+}
+
+func TestProcessingFlags(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"unset", nil, ""},
+		{"max depth", []string{"--max-depth", "2"}, "maxDepth=2"},
+		{"all", []string{"--max-depth", "2", "--max-collection-size", "100", "--max-objects", "5000"}, "maxCollectionSize=100&maxDepth=2&maxObjects=5000"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			queries := make(chan string, 1)
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				select {
+				case queries <- r.URL.RawQuery:
+				default:
+				}
+				io.WriteString(w, `{"status": 200, "timestamp": 1700000000, "value": {}}`)
+			}))
+			defer server.Close()
+
+			args := append([]string{"--stderr", "--jolokia", server.URL + "/jolokia"}, tt.args...)
+			if code, _, stderr := runMain(t, args...); code != 0 {
+				t.Fatalf("exit code = %d, stderr: %s", code, stderr)
+			}
+			if got := <-queries; got != tt.want {
+				t.Errorf("query = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTimestampSourceFlag(t *testing.T) {
+	tests := []struct {
+		name     string
+		source   string
+		wantCode int
+		want     string
+	}{
+		{"jolokia", "jolokia", 0, " 1700000000000000000"},
+		{"fixed", "2024-01-02T03:04:05Z", 0, " 1704164645000000000"},
+		{"invalid", "yesterday", exitConfig, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, stdout, stderr := runMain(t, "--stderr", "--measurement", "ckc", "--jolokia", stubJolokia(t), "--timestamp-source", tt.source)
+			if code != tt.wantCode {
+				t.Fatalf("exit code = %d, want %d; stderr: %s", code, tt.wantCode, stderr)
+			}
+			for _, line := range strings.Split(stdout, "\n") {
+				if strings.HasPrefix(line, "ckc,") && !strings.HasSuffix(line, tt.want) {
+					t.Errorf("line %q, want the timestamp%s", line, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestResolveHostname(t *testing.T) {
+	lookup := func(hostname string, err error) func() (string, error) {
+		return func() (string, error) { return hostname, err }
+	}
+	tests := []struct {
+		name     string
+		override string
+		lookup   func() (string, error)
+		want     string
+	}{
+		{"override", "cassandra-1", lookup("pod-1234", nil), "cassandra-1"},
+		{"lookup", "", lookup("pod-1234", nil), "pod-1234"},
+		{"lookup error", "", lookup("", errors.New("no hostname")), unknownHostname},
+		{"empty lookup", "", lookup("", nil), unknownHostname},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := resolveHostname(tt.override, tt.lookup); got != tt.want {
+				t.Errorf("resolveHostname() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWarnUnknownSkips(t *testing.T) {
+	const warning = "Filtered metric name matches no metric"
+	tests := []struct {
+		name     string
+		args     []string
+		wantCode int
+		want     string
+	}{
+		{"warned", []string{"--warn-unknown-skips", "--skip", "ReadLantency"}, 0, warning + " name=ReadLantency"},
+		{"not warned", []string{"--skip", "ReadLantency"}, 0, ""},
+		{"known", []string{"--warn-unknown-skips", "--skip", "ReadLatency"}, 0, ""},
+		{"check", []string{"--warn-unknown-skips", "--skip", "ReadLantency", "--check"}, exitConfig, "Unknown filtered names:   ReadLantency"},
+		{"skip ignored with include", []string{"--warn-unknown-skips", "--include", "ReadLatency", "--skip", "ReadLantency", "--check"}, 0, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, stdout, stderr := runMain(t, append([]string{"--stderr", "--jolokia", stubJolokia(t)}, tt.args...)...)
+			if code != tt.wantCode {
+				t.Fatalf("exit code = %d, want %d; stderr: %s", code, tt.wantCode, stderr)
+			}
+			output := stdout + stderr
+			if tt.want != "" && !strings.Contains(output, tt.want) {
+				t.Errorf("output %q, want %q", output, tt.want)
+			}
+			if tt.want == "" && strings.Contains(output, warning) {
+				t.Errorf("output %q, want no warning", output)
+			}
+		})
+	}
+}
+
+func TestDropEmptyTagsFlag(t *testing.T) {
+	value := `{"org.apache.cassandra.metrics:keyspace=app,name=ReadLatency,scope=,type=ColumnFamily": {"Count": 3}}`
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{"default", nil, "ckc,host=node1,keyspace=app,metric=ReadLatency Count=3i"},
+		{"kept", []string{"--no-drop-empty-tags"}, "ckc,cf=,host=node1,keyspace=app,metric=ReadLatency Count=3i"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			agent := answeringURL(t, http.StatusOK, `{"status": 200, "timestamp": 1700000000, "value": `+value+`}`)
+			args := append([]string{"--stderr", "--measurement", "ckc", "--hostname", "node1", "--jolokia", agent}, tt.args...)
+			code, stdout, stderr := runMain(t, args...)
+			if code != 0 {
+				t.Fatalf("exit code = %d, stderr: %s", code, stderr)
+			}
+			if !strings.Contains(stdout, tt.want+" ") {
+				t.Errorf("stdout = %q, want %q", stdout, tt.want)
+			}
+		})
+	}
+}
+
+func TestIPv6JolokiaFlag(t *testing.T) {
+	listener, err := net.Listen("tcp6", "[::1]:0")
+	if err != nil {
+		t.Skipf("no IPv6 loopback: %s", err)
+	}
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"status": 200, "timestamp": 1700000000, "value": {"org.apache.cassandra.metrics:keyspace=app,name=ReadLatency,scope=users,type=ColumnFamily": {"Count": 3}}}`)
+	}))
+	server.Listener.Close()
+	server.Listener = listener
+	server.Start()
+	defer server.Close()
+
+	agent := fmt.Sprintf("http://[::1]:%d/jolokia", listener.Addr().(*net.TCPAddr).Port)
+	code, stdout, stderr := runMain(t, "--stderr", "--measurement", "ckc", "--jolokia", agent)
+	if code != 0 {
+		t.Fatalf("exit code = %d, stderr: %s", code, stderr)
+	}
+	if got := collected(stdout); len(got) != 1 || got[0] != "app/users/ReadLatency" {
+		t.Errorf("collected %q, want app/users/ReadLatency", got)
+	}
+}
+
+func TestTargetURLFlags(t *testing.T) {
+	posted := make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		select {
+		case posted <- string(body):
+		default:
+		}
+		reads := []struct {
+			MBean string `json:"mbean"`
+		}{}
+		json.Unmarshal(body, &reads)
+		responses := []string{}
+		for _, read := range reads {
+			responses = append(responses, fmt.Sprintf(`{"request": {"type": "read", "mbean": %q}, "status": 200, "timestamp": 1700000000, "value": {
+				"org.apache.cassandra.metrics:keyspace=app,name=ReadLatency,scope=users,type=ColumnFamily": {"Count": 1}}}`, read.MBean))
+		}
+		io.WriteString(w, "["+strings.Join(responses, ",")+"]")
+	}))
+	defer server.Close()
+
+	code, _, stderr := runMain(t, "--stderr", "--jolokia", server.URL+"/jolokia",
+		"--target-url", "service:jmx:rmi:///jndi/rmi://cassandra-1:7199/jmxrmi", "--target-user", "monitor", "--target-password", "secret")
+	if code != 0 {
+		t.Fatalf("exit code = %d, stderr: %s", code, stderr)
+	}
+	want := `"target":{"url":"service:jmx:rmi:///jndi/rmi://cassandra-1:7199/jmxrmi","user":"monitor","password":"secret"}`
+	if body := <-posted; !strings.Contains(body, want) {
+		t.Errorf("posted %s, want the target %s", body, want)
+	}
+}
+
+func TestTimestampPrecisionFlag(t *testing.T) {
+	tests := []struct {
+		precision string
+		wantCode  int
+		want      string
+	}{
+		{"ns", 0, " 1700000000000000000"},
+		{"us", 0, " 1700000000000000"},
+		{"ms", 0, " 1700000000000"},
+		{"s", 0, " 1700000000"},
+		{"m", exitConfig, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.precision, func(t *testing.T) {
+			code, stdout, stderr := runMain(t, "--stderr", "--measurement", "ckc", "--jolokia", stubJolokia(t), "--timestamp-precision", tt.precision)
+			if code != tt.wantCode {
+				t.Fatalf("exit code = %d, want %d; stderr: %s", code, tt.wantCode, stderr)
+			}
+			lines := 0
+			for _, line := range strings.Split(stdout, "\n") {
+				if strings.HasPrefix(line, "ckc,") {
+					lines++
+					if !strings.HasSuffix(line, tt.want) {
+						t.Errorf("line %q, want the timestamp%s", line, tt.want)
+					}
+				}
+			}
+			if tt.wantCode == 0 && lines == 0 {
+				t.Errorf("stdout = %q, want the table metrics", stdout)
+			}
+		})
+	}
+}
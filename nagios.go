@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/CrossEngage/cassandra-keyspaces-checker/internal/checker"
+)
+
+// Nagios plugin exit codes.
+const (
+	nagiosOK = iota
+	nagiosWarning
+	nagiosCritical
+	nagiosUnknown
+)
+
+var nagiosStatuses = []string{"OK", "WARNING", "CRITICAL", "UNKNOWN"}
+
+// runNagios scrapes the jolokia agents once and exits as a Nagios plugin,
+// comparing the --check-field of every collected series against the --warn
+// and --critical thresholds.
+func runNagios(client *http.Client, cfg checker.Config) {
+	status, summary := nagiosUnknown, ""
+	if err := checkThresholds(flagIsSet("warn"), flagIsSet("critical"), *warnThreshold, *criticalThreshold); err != nil {
+		summary = err.Error()
+	} else {
+		status, summary = nagiosCheck(client, cfg, *checkField, *warnThreshold, *criticalThreshold)
+	}
+	fmt.Printf("%s - %s\n", nagiosStatuses[status], summary)
+	os.Exit(status)
+}
+
+// checkThresholds tells whether both thresholds are given, as defaulting
+// them would report any positive value as critical, and warn is not above
+// critical.
+func checkThresholds(warnSet, criticalSet bool, warn, critical float64) error {
+	if !warnSet || !criticalSet {
+		return errors.New("--warn and --critical are required")
+	}
+	if warn > critical {
+		return fmt.Errorf("--warn %s is above --critical %s", formatFloat(warn), formatFloat(critical))
+	}
+	return nil
+}
+
+// flagIsSet tells whether the flag was given on the command line or in the
+// config file.
+func flagIsSet(name string) bool {
+	return commandLineFlags[name] || len(app.GetFlag(name).Model().Default) > 0
+}
+
+// nagiosCheck returns the status and the summary line, with the perfdata, of
+// the worst series.
+func nagiosCheck(client *http.Client, cfg checker.Config, key string, warn, critical float64) (int, string) {
+	if key == "" {
+		return nagiosUnknown, "--check-field is required"
+	}
+
+	resps := []*checker.Response{}
+	for _, result := range checker.FetchAll(context.Background(), client, cfg) {
+		if result.Err != nil {
+			return nagiosUnknown, fmt.Sprintf("scrape of %s failed: %s", result.URL, result.Err)
+		}
+		resps = append(resps, result.Response)
+	}
+
+	values := checker.FieldValues(resps, cfg, key)
+	if len(values) == 0 {
+		return nagiosUnknown, fmt.Sprintf("no series with a %s field", key)
+	}
+
+	status := nagiosOK
+	worst := values[0]
+	perfdata := []string{}
+	for _, v := range values {
+		s := nagiosStatus(v.Value, warn, critical)
+		if s > status || (s == status && v.Value > worst.Value) {
+			status, worst = s, v
+		}
+		perfdata = append(perfdata, fmt.Sprintf("'%s.%s'=%s;%s;%s", v.Series, key,
+			formatFloat(v.Value), formatFloat(warn), formatFloat(critical)))
+	}
+
+	return status, fmt.Sprintf("%s.%s is %s | %s", worst.Series, key,
+		formatFloat(worst.Value), strings.Join(perfdata, " "))
+}
+
+// nagiosStatus compares value against the thresholds, higher being worse.
+func nagiosStatus(value, warn, critical float64) int {
+	switch {
+	case value > critical:
+		return nagiosCritical
+	case value > warn:
+		return nagiosWarning
+	}
+	return nagiosOK
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckThresholds(t *testing.T) {
+	tests := []struct {
+		name                 string
+		warnSet, criticalSet bool
+		warn, critical       float64
+		wantErr              string
+	}{
+		{"both set", true, true, 1000, 5000, ""},
+		{"equal", true, true, 1000, 1000, ""},
+		{"warn missing", false, true, 0, 5000, "--warn and --critical are required"},
+		{"critical missing", true, false, 1000, 0, "--warn and --critical are required"},
+		{"none set", false, false, 0, 0, "--warn and --critical are required"},
+		{"warn above critical", true, true, 5000, 1000, "--warn 5000 is above --critical 1000"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkThresholds(tt.warnSet, tt.criticalSet, tt.warn, tt.critical)
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("checkThresholds() error = %v, want none", err)
+			}
+			if tt.wantErr != "" && (err == nil || err.Error() != tt.wantErr) {
+				t.Errorf("checkThresholds() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNagiosStatus(t *testing.T) {
+	tests := []struct {
+		value float64
+		want  int
+	}{
+		{500, nagiosOK},
+		{1000, nagiosOK},
+		{1001, nagiosWarning},
+		{5000, nagiosWarning},
+		{5001, nagiosCritical},
+	}
+	for _, tt := range tests {
+		if got := nagiosStatus(tt.value, 1000, 5000); got != tt.want {
+			t.Errorf("nagiosStatus(%v, 1000, 5000) = %d, want %d", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestNagiosWithoutThresholds(t *testing.T) {
+	code, stdout, _ := runMain(t, "--stderr", "--nagios", "--check-field", "Mean")
+	if code != nagiosUnknown || !strings.HasPrefix(stdout, "UNKNOWN - --warn and --critical are required") {
+		t.Errorf("exit code = %d, output = %q, want UNKNOWN for the missing thresholds", code, stdout)
+	}
+}
+
+func TestNagiosExitCodes(t *testing.T) {
+	tests := []struct {
+		name           string
+		url            func(t *testing.T) string
+		warn, critical string
+		wantCode       int
+		wantPrefix     string
+	}{
+		{"ok", stubJolokia, "1", "2", nagiosOK, "OK - "},
+		{"warning", stubJolokia, "0.5", "2", nagiosWarning, "WARNING - "},
+		{"critical", stubJolokia, "0", "0.5", nagiosCritical, "CRITICAL - "},
+		{"unreachable", closedURL, "1", "2", nagiosUnknown, "UNKNOWN - scrape of "},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, stdout, _ := runMain(t, "--stderr", "--jolokia", tt.url(t), "--nagios", "--check-field", "Count", "--warn", tt.warn, "--critical", tt.critical)
+			if code != tt.wantCode || !strings.HasPrefix(stdout, tt.wantPrefix) {
+				t.Errorf("exit code = %d, output = %q, want %d and %q", code, stdout, tt.wantCode, tt.wantPrefix)
+			}
+		})
+	}
+}
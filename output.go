@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// outputFormats are the formats of --output-format and --output.
+var outputFormats = []string{"influx", "prometheus", "graphite", "json", "opentsdb", "statsd", "table"}
+
+// outputOptions are the settings the sinks share.
+type outputOptions struct {
+	// flushLines and flushInterval, when set, flush the output every that
+	// many lines or that often.
+	flushLines    int
+	flushInterval time.Duration
+	// dialTimeout and writeTimeout bound the socket connections and writes.
+	dialTimeout  time.Duration
+	writeTimeout time.Duration
+}
+
+// sink is a destination of the scrapes, receiving their lines rendered in
+// its format.
+type sink interface {
+	format() string
+	write(lines []string) error
+}
+
+// stdoutSink prints the lines.
+type stdoutSink struct {
+	outputFormat string
+	opts         outputOptions
+}
+
+func (s stdoutSink) format() string             { return s.outputFormat }
+func (s stdoutSink) write(lines []string) error { return writeLines(os.Stdout, lines, s.opts) }
+
+// fileSink atomically replaces a file with the lines, such as a textfile of
+// node_exporter.
+type fileSink struct {
+	outputFormat, filename string
+	opts                   outputOptions
+}
+
+func (s fileSink) format() string             { return s.outputFormat }
+func (s fileSink) write(lines []string) error { return writeFileAtomic(s.filename, lines, s.opts) }
+
+// statsdSink sends the statsd lines over UDP.
+type statsdSink struct{ addr string }
+
+func (s statsdSink) format() string             { return "statsd" }
+func (s statsdSink) write(lines []string) error { return sendStatsd(s.addr, lines) }
+
+// socketSink writes the lines to a unix or tcp socket.
+type socketSink struct {
+	outputFormat string
+	socket       *socketWriter
+}
+
+func (s socketSink) format() string             { return s.outputFormat }
+func (s socketSink) write(lines []string) error { return s.socket.write(lines) }
+
+// parseSink parses an --output, format[:destination]. The destination is
+// stdout when empty or -, a socket with a unix:// or tcp:// scheme, the
+// statsd host:port for the statsd format, and a file otherwise.
+func parseSink(spec string, opts outputOptions) (sink, error) {
+	format, dest, _ := strings.Cut(spec, ":")
+	if !hasFormat(outputFormats, format) {
+		return nil, fmt.Errorf("unknown output format %q", format)
+	}
+	switch {
+	case dest == "" || dest == "-":
+		return stdoutSink{format, opts}, nil
+	case strings.Contains(dest, "://"):
+		w, err := newSocketWriter(dest, opts)
+		if err != nil {
+			return nil, err
+		}
+		return socketSink{format, w}, nil
+	case format == "statsd":
+		return statsdSink{dest}, nil
+	}
+	return fileSink{format, dest, opts}, nil
+}
+
+// defaultSink is the single sink of --output-format, --socket,
+// --statsd-addr and --output-file, used when no --output is given.
+func defaultSink(format, socketAddr, statsdAddr, outputFile string, opts outputOptions) (sink, error) {
+	switch {
+	case socketAddr != "":
+		w, err := newSocketWriter(socketAddr, opts)
+		if err != nil {
+			return nil, err
+		}
+		return socketSink{format, w}, nil
+	case statsdAddr != "":
+		return statsdSink{statsdAddr}, nil
+	case outputFile != "":
+		return fileSink{format, outputFile, opts}, nil
+	}
+	return stdoutSink{format, opts}, nil
+}
+
+// sinkFormats returns the formats the sinks need, each once.
+func sinkFormats(sinks []sink) []string {
+	formats := []string{}
+	for _, s := range sinks {
+		if !hasFormat(formats, s.format()) {
+			formats = append(formats, s.format())
+		}
+	}
+	return formats
+}
+
+func hasFormat(formats []string, format string) bool {
+	for _, f := range formats {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+// writeOutputs writes every sink its lines, going on with the others when
+// one fails. Files are left untouched on failed scrapes, so only the other
+// sinks get the failed heartbeat.
+func writeOutputs(sinks []sink, outputs map[string][]string, failed bool) error {
+	errs := []error{}
+	for _, s := range sinks {
+		lines := outputs[s.format()]
+		if _, ok := s.(fileSink); ok && failed || lines == nil {
+			continue
+		}
+		if err := s.write(lines); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// writeLines writes the lines to w through a buffer, flushed at the end and,
+// when set, every opts.flushLines lines or opts.flushInterval, so long
+// outputs stream out. Flushes happen between lines, never splitting one.
+func writeLines(w io.Writer, lines []string, opts outputOptions) error {
+	bw := bufio.NewWriter(w)
+	lastFlush := time.Now()
+	for i, line := range lines {
+		bw.WriteString(line)
+		bw.WriteByte('\n')
+
+		if (opts.flushLines > 0 && (i+1)%opts.flushLines == 0) ||
+			(opts.flushInterval > 0 && time.Since(lastFlush) >= opts.flushInterval) {
+			if err := bw.Flush(); err != nil {
+				return err
+			}
+			lastFlush = time.Now()
+		}
+	}
+	return bw.Flush()
+}
+
+// writeFileAtomic writes the lines to a temporary file next to filename and
+// renames it over filename, so readers never see a partially written file.
+func writeFileAtomic(filename string, lines []string, opts outputOptions) error {
+	tmp, err := os.CreateTemp(filepath.Dir(filename), "."+filepath.Base(filename)+".")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := writeLines(tmp, lines, opts); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filename)
+}
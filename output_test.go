@@ -0,0 +1,269 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/CrossEngage/cassandra-keyspaces-checker/internal/checker"
+)
+
+// flushCounter counts the writes reaching it, one per flush of writeLines.
+type flushCounter struct {
+	writes int
+	data   strings.Builder
+}
+
+func (f *flushCounter) Write(p []byte) (int, error) {
+	f.writes++
+	return f.data.Write(p)
+}
+
+func TestWriteLines(t *testing.T) {
+	lines := []string{"a 1", "b 2", "c 3", "d 4", "e 5"}
+	tests := []struct {
+		name       string
+		opts       outputOptions
+		wantWrites int
+	}{
+		{"flush at the end only", outputOptions{}, 1},
+		{"flush every 2 lines", outputOptions{flushLines: 2}, 3},
+		{"flush every line", outputOptions{flushLines: 1}, 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := &flushCounter{}
+			if err := writeLines(w, lines, tt.opts); err != nil {
+				t.Fatal(err)
+			}
+			if w.writes != tt.wantWrites {
+				t.Errorf("writeLines() flushed %d times, want %d", w.writes, tt.wantWrites)
+			}
+			if want := strings.Join(lines, "\n") + "\n"; w.data.String() != want {
+				t.Errorf("writeLines() wrote %q, want %q", w.data.String(), want)
+			}
+		})
+	}
+}
+
+// chunkRecorder records every write reaching it.
+type chunkRecorder struct{ chunks []string }
+
+func (c *chunkRecorder) Write(p []byte) (int, error) {
+	c.chunks = append(c.chunks, string(p))
+	return len(p), nil
+}
+
+func TestWriteLinesIncremental(t *testing.T) {
+	lines := []string{"a 1", "b 2", "c 3", "d 4", "e 5"}
+	tests := []struct {
+		name string
+		opts outputOptions
+		want []string
+	}{
+		{"all at once", outputOptions{}, []string{"a 1\nb 2\nc 3\nd 4\ne 5\n"}},
+		{"every 2 lines", outputOptions{flushLines: 2}, []string{"a 1\nb 2\n", "c 3\nd 4\n", "e 5\n"}},
+		{"every line", outputOptions{flushLines: 1}, []string{"a 1\n", "b 2\n", "c 3\n", "d 4\n", "e 5\n"}},
+		{"interval not elapsed", outputOptions{flushInterval: time.Hour}, []string{"a 1\nb 2\nc 3\nd 4\ne 5\n"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := &chunkRecorder{}
+			if err := writeLines(w, lines, tt.opts); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(w.chunks, tt.want) {
+				t.Errorf("writeLines() wrote %q, want %q", w.chunks, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteFileAtomic(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "cassandra.prom")
+	if err := os.WriteFile(filename, []byte("old 1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeFileAtomic(filename, []string{"new 2"}, outputOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "new 2\n" {
+		t.Errorf("file = %q, want %q", data, "new 2\n")
+	}
+	entries, _ := os.ReadDir(filepath.Dir(filename))
+	if len(entries) != 1 {
+		t.Errorf("directory has %d entries, want no temporary file left", len(entries))
+	}
+}
+
+func TestParseSink(t *testing.T) {
+	opts := outputOptions{flushLines: 10}
+	tests := []struct {
+		spec    string
+		want    sink
+		wantErr bool
+	}{
+		{"influx", stdoutSink{"influx", opts}, false},
+		{"json:-", stdoutSink{"json", opts}, false},
+		{"prometheus:/var/lib/node_exporter/cassandra.prom", fileSink{"prometheus", "/var/lib/node_exporter/cassandra.prom", opts}, false},
+		{"statsd:127.0.0.1:8125", statsdSink{"127.0.0.1:8125"}, false},
+		{"xml", nil, true},
+		{"influx:udp://127.0.0.1:8094", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			got, err := parseSink(tt.spec, opts)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseSink() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseSink() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSinkSocket(t *testing.T) {
+	opts := outputOptions{dialTimeout: time.Second, writeTimeout: 2 * time.Second}
+	got, err := parseSink("influx:tcp://127.0.0.1:8094", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, ok := got.(socketSink)
+	if !ok {
+		t.Fatalf("parseSink() = %#v, want a socketSink", got)
+	}
+	if s.socket.network != "tcp" || s.socket.addr != "127.0.0.1:8094" || s.socket.opts != opts {
+		t.Errorf("socket = %+v, want tcp 127.0.0.1:8094 with the given options", s.socket)
+	}
+}
+
+func TestDefaultSink(t *testing.T) {
+	opts := outputOptions{flushLines: 5}
+	tests := []struct {
+		name                               string
+		socketAddr, statsdAddr, outputFile string
+		want                               sink
+	}{
+		{"stdout", "", "", "", stdoutSink{"influx", opts}},
+		{"file", "", "", "out.txt", fileSink{"influx", "out.txt", opts}},
+		{"statsd over file", "", "127.0.0.1:8125", "out.txt", statsdSink{"127.0.0.1:8125"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := defaultSink("influx", tt.socketAddr, tt.statsdAddr, tt.outputFile, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("defaultSink() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSocketWriterBatches(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	received := make(chan []string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		lines := []string{}
+		scanner := bufio.NewScanner(conn)
+		for scanner.Scan() {
+			lines = append(lines, scanner.Text())
+		}
+		received <- lines
+	}()
+
+	w, err := newSocketWriter("tcp://"+listener.Addr().String(), outputOptions{flushLines: 2, dialTimeout: time.Second, writeTimeout: time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"a 1", "b 2", "c 3"}
+	if err := w.write(want); err != nil {
+		t.Fatal(err)
+	}
+	w.conn.Close()
+	select {
+	case got := <-received:
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("received %q, want %q", got, want)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the lines")
+	}
+}
+
+// memorySink keeps the lines written to it.
+type memorySink struct {
+	outputFormat string
+	lines        []string
+}
+
+func (s *memorySink) format() string { return s.outputFormat }
+func (s *memorySink) write(lines []string) error {
+	s.lines = append(s.lines, lines...)
+	return nil
+}
+
+func TestWriteOutputsFanOut(t *testing.T) {
+	jolokiaURL, err := url.Parse(stubJolokia(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := checker.Config{JolokiaURLs: []*url.URL{jolokiaURL}, Measurement: "ckc", Hostname: "node1", OutputFormat: "influx"}
+	influx := &memorySink{outputFormat: "influx"}
+	prometheus := &memorySink{outputFormat: "prometheus"}
+	sinks := []sink{influx, prometheus}
+
+	outputs, err := checker.ScrapeFormats(context.Background(), http.DefaultClient, cfg, sinkFormats(sinks))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := writeOutputs(sinks, outputs, false); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []*memorySink{influx, prometheus} {
+		if len(s.lines) == 0 {
+			t.Errorf("%s sink received no lines", s.outputFormat)
+		}
+		if !reflect.DeepEqual(s.lines, outputs[s.outputFormat]) {
+			t.Errorf("%s sink received %q, want %q", s.outputFormat, s.lines, outputs[s.outputFormat])
+		}
+	}
+	series := func(lines []string, prefix string) int {
+		n := 0
+		for _, line := range lines {
+			if strings.HasPrefix(line, prefix) {
+				n++
+			}
+		}
+		return n
+	}
+	if got := series(influx.lines, "ckc,"); got != len(tableMetrics) {
+		t.Errorf("influx sink received %d series, want %d", got, len(tableMetrics))
+	}
+	if got := series(prometheus.lines, "cassandra_columnfamily_"); got != len(tableMetrics) {
+		t.Errorf("prometheus sink received %d series, want %d", got, len(tableMetrics))
+	}
+}
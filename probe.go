@@ -0,0 +1,25 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+
+	"github.com/CrossEngage/cassandra-keyspaces-checker/internal/checker"
+)
+
+// probeAgents logs the version and limits of every jolokia agent. Agents
+// whose version cannot be read are only warned about, as older or locked
+// down agents may still serve reads.
+func probeAgents(client *http.Client, cfg checker.Config) {
+	for _, baseURL := range cfg.JolokiaURLs {
+		version, err := checker.ProbeVersion(context.Background(), client, baseURL, cfg)
+		if err != nil {
+			slog.Warn("Could not read the jolokia version", "url", baseURL.String(), "error", err)
+			continue
+		}
+		slog.Info("Jolokia agent", "url", baseURL.String(), "agent", version.Agent, "protocol", version.Protocol,
+			"max_depth", version.Config["maxDepth"], "max_objects", version.Config["maxObjects"],
+			"max_collection_size", version.Config["maxCollectionSize"])
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/CrossEngage/cassandra-keyspaces-checker/internal/checker"
+)
+
+func TestProbeAgents(t *testing.T) {
+	tests := []struct {
+		name    string
+		version func(w http.ResponseWriter)
+		want    map[string]interface{}
+	}{
+		{"logged", func(w http.ResponseWriter) {
+			io.WriteString(w, `{"status": 200, "value": {"agent": "1.7.2", "protocol": "7.2", "config": {"maxDepth": "15", "maxObjects": "0", "maxCollectionSize": "0"}}}`)
+		}, map[string]interface{}{
+			"level": "INFO", "msg": "Jolokia agent", "agent": "1.7.2", "protocol": "7.2",
+			"max_depth": "15", "max_objects": "0", "max_collection_size": "0",
+		}},
+		{"unreachable version", func(w http.ResponseWriter) {
+			w.WriteHeader(http.StatusNotFound)
+		}, map[string]interface{}{"level": "WARN", "msg": "Could not read the jolokia version"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				tt.version(w)
+			}))
+			defer server.Close()
+			baseURL, err := url.Parse(server.URL + "/jolokia")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			logs := &bytes.Buffer{}
+			previous := slog.Default()
+			slog.SetDefault(slog.New(slog.NewJSONHandler(logs, nil)))
+			defer slog.SetDefault(previous)
+
+			probeAgents(http.DefaultClient, checker.Config{JolokiaURLs: []*url.URL{baseURL}})
+
+			record := map[string]interface{}{}
+			if err := json.Unmarshal(logs.Bytes(), &record); err != nil {
+				t.Fatalf("logged %q, want one record: %s", logs, err)
+			}
+			if record["url"] != baseURL.String() {
+				t.Errorf("url = %v, want %s", record["url"], baseURL)
+			}
+			for key, want := range tt.want {
+				if record[key] != want {
+					t.Errorf("%s = %v, want %v", key, record[key], want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"regexp"
+	"strconv"
+	"sync"
+
+	"github.com/CrossEngage/cassandra-keyspaces-checker/internal/checker"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+var (
+	// builtinDefaults are the flag defaults before --config overrides them.
+	builtinDefaults = map[string][]string{}
+	// commandLineFlags are the flags given on the command line, which a
+	// reload must not override.
+	commandLineFlags = map[string]bool{}
+)
+
+// recordFlagSources remembers the built in defaults and which flags are on
+// the command line, so the filters can be reloaded with the same precedence.
+// It must run before --config is loaded.
+func recordFlagSources(args []string) {
+	for _, flag := range app.Model().Flags {
+		builtinDefaults[flag.Name] = flag.Default
+	}
+	context, err := app.ParseContext(args)
+	if err != nil {
+		return
+	}
+	for _, element := range context.Elements {
+		if flag, ok := element.Clause.(*kingpin.FlagClause); ok {
+			commandLineFlags[flag.Model().Name] = true
+		}
+	}
+}
+
+// liveConfig holds the configuration used by the scrapes, whose filters can
+// be swapped by a reload between two scrapes.
+type liveConfig struct {
+	mu  sync.Mutex
+	cfg checker.Config
+}
+
+func (l *liveConfig) get() checker.Config {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.cfg
+}
+
+// reloadFilters re-reads --config and --skip-file and swaps the filters they
+// set. Filters given on the command line are kept. On error, the previous
+// filters stay in use.
+func (l *liveConfig) reloadFilters() (checker.Config, error) {
+	file := map[string][]string{}
+	if *configFile != "" {
+		var err error
+		if file, err = readConfigFile(*configFile); err != nil {
+			return checker.Config{}, err
+		}
+	}
+	value := func(name string, current []string) []string {
+		if commandLineFlags[name] {
+			return current
+		}
+		if values, ok := file[name]; ok {
+			return values
+		}
+		return builtinDefaults[name]
+	}
+
+	skip, err := skipList(splitCSV(value("skip", *skipMetrics)), *skipFile)
+	if err != nil {
+		return checker.Config{}, err
+	}
+	skipRegex, err := compileSkipRegex(value("skip-regex", *skipPatterns))
+	if err != nil {
+		return checker.Config{}, err
+	}
+	skipSystem := false
+	if values := value("skip-system-keyspaces", []string{strconv.FormatBool(*skipSystemKeyspaces)}); len(values) > 0 {
+		if skipSystem, err = strconv.ParseBool(values[len(values)-1]); err != nil {
+			return checker.Config{}, err
+		}
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.cfg.Skip = skip
+	l.cfg.SkipRegex = skipRegex
+	l.cfg.Include, l.cfg.Fields = latencyPreset(*latencyOnly, splitCSV(value("include", *includeMetrics)), value("fields", *fields))
+	l.cfg.Keyspaces = splitCSV(value("keyspace", *keyspaces))
+	l.cfg.SkipKeyspaces = splitCSV(value("skip-keyspace", *skipKeyspaces))
+	l.cfg.SkipSystemKeyspaces = skipSystem
+	l.cfg.Tables = splitCSV(value("table", *tables))
+	l.cfg.SkipTables = splitCSV(value("skip-table", *skipTables))
+	l.cfg.SkipFields = value("skip-fields", *skipFields)
+	return l.cfg, nil
+}
+
+// compileSkipRegex compiles the --skip-regex patterns.
+func compileSkipRegex(patterns []string) ([]*regexp.Regexp, error) {
+	skipRegex := []*regexp.Regexp{}
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, err
+		}
+		skipRegex = append(skipRegex, re)
+	}
+	return skipRegex, nil
+}
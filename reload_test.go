@@ -0,0 +1,60 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/CrossEngage/cassandra-keyspaces-checker/internal/checker"
+)
+
+// setString sets a flag value for the duration of the test.
+func setString(t *testing.T, flag *string, value string) {
+	t.Helper()
+	old := *flag
+	*flag = value
+	t.Cleanup(func() { *flag = old })
+}
+
+func TestReloadFilters(t *testing.T) {
+	dir := t.TempDir()
+	skipFilename := filepath.Join(dir, "skip.txt")
+	configFilename := filepath.Join(dir, "checker.yaml")
+	write := func(filename, content string) {
+		if err := os.WriteFile(filename, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(skipFilename, "ReadLatency\n")
+	write(configFilename, "keyspace: app\n")
+	setString(t, skipFile, skipFilename)
+	setString(t, configFile, configFilename)
+
+	l := &liveConfig{cfg: checker.Config{Hostname: "node1", Skip: []string{"ReadLatency"}, Keyspaces: []string{"app"}}}
+	write(skipFilename, "ReadLatency\nWriteLatency\n")
+	write(configFilename, "keyspace: [app, logs]\n")
+	cfg, err := l.reloadFilters()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, got := range []checker.Config{cfg, l.get()} {
+		if !reflect.DeepEqual(got.Skip, []string{"ReadLatency", "WriteLatency"}) {
+			t.Errorf("Skip = %q, want the entries of the changed skip file", got.Skip)
+		}
+		if !reflect.DeepEqual(got.Keyspaces, []string{"app", "logs"}) {
+			t.Errorf("Keyspaces = %q, want those of the changed config file", got.Keyspaces)
+		}
+		if got.Hostname != "node1" {
+			t.Errorf("Hostname = %q, want the settings other than the filters kept", got.Hostname)
+		}
+	}
+
+	write(configFilename, "keyspace: [app\n")
+	if _, err := l.reloadFilters(); err == nil {
+		t.Error("reloadFilters() error = nil, want the parse error of the config file")
+	}
+	if got := l.get(); !reflect.DeepEqual(got.Keyspaces, []string{"app", "logs"}) || len(got.Skip) != 2 {
+		t.Errorf("filters after a failed reload = %q, %q, want the previous ones", got.Keyspaces, got.Skip)
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestGracefulContext(t *testing.T) {
+	tests := []struct {
+		name       string
+		signal     bool
+		timeout    time.Duration
+		wantCancel bool
+	}{
+		{"no signal", false, 10 * time.Millisecond, false},
+		{"within the timeout", true, time.Hour, false},
+		{"timeout elapsed", true, 10 * time.Millisecond, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, stop := context.WithCancel(context.Background())
+			defer stop()
+			work, cancel := gracefulContext(ctx, tt.timeout)
+			defer cancel()
+			if tt.signal {
+				stop()
+			}
+
+			select {
+			case <-work.Done():
+				if !tt.wantCancel {
+					t.Error("work was cancelled before its timeout")
+				}
+			case <-time.After(200 * time.Millisecond):
+				if tt.wantCancel {
+					t.Error("work was not cancelled after its timeout")
+				}
+			}
+		})
+	}
+}
+
+// slowAgent runs a jolokia agent answering after delay, or when the client
+// goes away. started receives a value when a scrape comes in.
+func slowAgent(t *testing.T, delay time.Duration) (string, chan struct{}) {
+	t.Helper()
+	started := make(chan struct{}, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case started <- struct{}{}:
+		default:
+		}
+		select {
+		case <-time.After(delay):
+		case <-r.Context().Done():
+			return
+		}
+		io.WriteString(w, `{"status": 200, "timestamp": 1700000000, "value": {"org.apache.cassandra.metrics:keyspace=app,name=ReadLatency,scope=users,type=ColumnFamily": {"Count": 1}}}`)
+	}))
+	t.Cleanup(server.Close)
+	return server.URL + "/jolokia", started
+}
+
+func TestShutdownDuringScrape(t *testing.T) {
+	tests := []struct {
+		name            string
+		delay           time.Duration
+		shutdownTimeout string
+		wantSeries      bool
+		within          time.Duration
+	}{
+		{"scrape finishes", 500 * time.Millisecond, "10s", true, 5 * time.Second},
+		{"timeout reached", time.Minute, "200ms", false, 5 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			agent, started := slowAgent(t, tt.delay)
+			var stdout, stderr bytes.Buffer
+			cmd := startMain(t, &stdout, &stderr, "--stderr", "--measurement", "ckc", "--jolokia", agent,
+				"--interval", "1h", "--shutdown-timeout", tt.shutdownTimeout)
+			select {
+			case <-started:
+			case <-time.After(10 * time.Second):
+				t.Fatal("timed out waiting for the scrape")
+			}
+
+			if err := cmd.Process.Signal(syscall.SIGTERM); err != nil {
+				t.Fatal(err)
+			}
+			done := make(chan error, 1)
+			go func() { done <- cmd.Wait() }()
+			select {
+			case err := <-done:
+				if err != nil {
+					t.Fatalf("exit error = %v; stderr: %s", err, stderr.String())
+				}
+			case <-time.After(tt.within):
+				t.Fatalf("still running %s after SIGTERM", tt.within)
+			}
+
+			out := stdout.String()
+			if out != "" && !strings.HasSuffix(out, "\n") {
+				t.Errorf("stdout ends with a torn line: %q", out)
+			}
+			series := strings.Contains(out, "ckc,cf=users,host=")
+			if series != tt.wantSeries {
+				t.Errorf("series written = %v, want %v; stdout: %q", series, tt.wantSeries, out)
+			}
+		})
+	}
+}
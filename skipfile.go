@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"os"
+	"strings"
+)
+
+// readSkipFile returns the metric names listed in filename, one per line,
+// ignoring blank lines and comments starting with #.
+func readSkipFile(filename string) ([]string, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	names := []string{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		if line = strings.TrimSpace(line); line != "" {
+			names = append(names, line)
+		}
+	}
+	return names, scanner.Err()
+}
+
+// splitCSV splits the values of a repeatable CSV flag into its entries.
+// Entries containing commas can be quoted, as in "a,b". Values that are not
+// valid CSV are kept as they are.
+func splitCSV(values []string) []string {
+	entries := []string{}
+	for _, value := range values {
+		r := csv.NewReader(strings.NewReader(value))
+		r.TrimLeadingSpace = true
+		record, err := r.Read()
+		if err != nil {
+			entries = append(entries, value)
+			continue
+		}
+		for _, entry := range record {
+			if entry = strings.TrimSpace(entry); entry != "" {
+				entries = append(entries, entry)
+			}
+		}
+	}
+	return entries
+}
+
+// skipList returns the skip entries merged with those of the skip file, if
+// any.
+func skipList(skip []string, skipFile string) ([]string, error) {
+	if skipFile == "" {
+		return skip, nil
+	}
+	names, err := readSkipFile(skipFile)
+	if err != nil {
+		return nil, err
+	}
+	return append(append([]string{}, skip...), names...), nil
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSkipList(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "skip.txt")
+	content := "# noisy metrics\nBloomFilterFalseRatio\n\n  SnapshotsSize # unused\n"
+	if err := os.WriteFile(filename, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name     string
+		skip     []string
+		skipFile string
+		want     []string
+		wantErr  bool
+	}{
+		{"no skip file", []string{"ReadLatency"}, "", []string{"ReadLatency"}, false},
+		{"merged with the skip file", []string{"ReadLatency"}, filename, []string{"ReadLatency", "BloomFilterFalseRatio", "SnapshotsSize"}, false},
+		{"missing skip file", nil, filepath.Join(t.TempDir(), "missing.txt"), nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := skipList(tt.skip, tt.skipFile)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("skipList() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("skipList() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitCSV(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []string
+		want   []string
+	}{
+		{"csv", []string{"ReadLatency,WriteLatency"}, []string{"ReadLatency", "WriteLatency"}},
+		{"repeated", []string{"ReadLatency", "WriteLatency"}, []string{"ReadLatency", "WriteLatency"}},
+		{"spaces and empty entries", []string{" ReadLatency, ,WriteLatency ,"}, []string{"ReadLatency", "WriteLatency"}},
+		{"quoted comma", []string{`"Read,Latency",WriteLatency`}, []string{"Read,Latency", "WriteLatency"}},
+		{"invalid csv kept", []string{`Read"Latency`}, []string{`Read"Latency`}},
+		{"none", nil, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := splitCSV(tt.values); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("splitCSV(%q) = %q, want %q", tt.values, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLatencyPreset(t *testing.T) {
+	tests := []struct {
+		name                    string
+		enabled                 bool
+		include, fields         []string
+		wantInclude, wantFields []string
+	}{
+		{"disabled", false, nil, nil, nil, nil},
+		{"enabled", true, nil, nil, latencyMetrics, latencyFields},
+		{"explicit include", true, []string{"ReadLatency"}, nil, []string{"ReadLatency"}, latencyFields},
+		{"explicit fields", true, nil, []string{"Mean"}, latencyMetrics, []string{"Mean"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			include, fields := latencyPreset(tt.enabled, tt.include, tt.fields)
+			if !reflect.DeepEqual(include, tt.wantInclude) || !reflect.DeepEqual(fields, tt.wantFields) {
+				t.Errorf("latencyPreset() = %q, %q, want %q, %q", include, fields, tt.wantInclude, tt.wantFields)
+			}
+		})
+	}
+}
+
+func TestReadSkipFile(t *testing.T) {
+	tests := []struct {
+		name, content string
+		want          []string
+	}{
+		{"names", "ReadLatency\nWriteLatency\n", []string{"ReadLatency", "WriteLatency"}},
+		{"comments and blank lines", "# latency\n\nReadLatency\n   \n  # indented comment\nWriteLatency # trailing comment\n", []string{"ReadLatency", "WriteLatency"}},
+		{"no trailing newline", "ReadLatency", []string{"ReadLatency"}},
+		{"windows line endings", "ReadLatency\r\nWriteLatency\r\n", []string{"ReadLatency", "WriteLatency"}},
+		{"empty", "", []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filename := filepath.Join(t.TempDir(), "skip.txt")
+			if err := os.WriteFile(filename, []byte(tt.content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			got, err := readSkipFile(filename)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("readSkipFile() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSkipFileFlag(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "skip.txt")
+	if err := os.WriteFile(filename, []byte("# noisy\nReadLatency\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	got := runFilters(t, "--skip-file", filename, "--skip", "WriteLatency")
+	want := []string{"app/users/LiveDiskSpaceUsed"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("collected %q, want %q", got, want)
+	}
+}
+
+func TestLatencyOnlyFlag(t *testing.T) {
+	value := `{
+		"org.apache.cassandra.metrics:keyspace=app,name=ReadLatency,scope=users,type=ColumnFamily": {"Count": 3, "Mean": 1.5, "99thPercentile": 20, "999thPercentile": 40},
+		"org.apache.cassandra.metrics:keyspace=app,name=LiveDiskSpaceUsed,scope=users,type=ColumnFamily": {"Count": 1024}
+	}`
+	tests := []struct {
+		name string
+		args []string
+		want []string
+	}{
+		{"preset", []string{"--latency-only"}, []string{
+			"ckc,cf=users,host=node1,keyspace=app,metric=ReadLatency 999thPercentile=40i,99thPercentile=20i 1700000000000000000",
+		}},
+		{"explicit fields", []string{"--latency-only", "--fields", "Mean"}, []string{
+			"ckc,cf=users,host=node1,keyspace=app,metric=ReadLatency Mean=1.500000 1700000000000000000",
+		}},
+		{"explicit include", []string{"--latency-only", "--include", "LiveDiskSpaceUsed", "--fields", "Count"}, []string{
+			"ckc,cf=users,host=node1,keyspace=app,metric=LiveDiskSpaceUsed Count=1024i 1700000000000000000",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			agent := answeringURL(t, http.StatusOK, `{"status": 200, "timestamp": 1700000000, "value": `+value+`}`)
+			args := append([]string{"--stderr", "--measurement", "ckc", "--hostname", "node1", "--jolokia", agent}, tt.args...)
+			code, stdout, stderr := runMain(t, args...)
+			if code != 0 {
+				t.Fatalf("exit code = %d, stderr: %s", code, stderr)
+			}
+			got := []string{}
+			for _, line := range strings.Split(stdout, "\n") {
+				if strings.HasPrefix(line, "ckc,") {
+					got = append(got, line)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("output %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
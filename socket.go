@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"net"
+	"strings"
+	"time"
+)
+
+// socketWriter writes the lines to a unix or tcp socket, such as the one of
+// telegraf's socket_listener. The connection is kept between scrapes and
+// opened again when it drops.
+type socketWriter struct {
+	network string
+	addr    string
+	opts    outputOptions
+	conn    net.Conn
+}
+
+// newSocketWriter parses addr, either unix:///path, tcp://host:port, a path
+// or a host:port.
+func newSocketWriter(addr string, opts outputOptions) (*socketWriter, error) {
+	network, address := "tcp", addr
+	if i := strings.Index(addr, "://"); i >= 0 {
+		network, address = addr[:i], addr[i+3:]
+	} else if strings.HasPrefix(addr, "/") {
+		network = "unix"
+	}
+	switch network {
+	case "unix", "tcp", "tcp4", "tcp6":
+	default:
+		return nil, fmt.Errorf("unsupported socket network %q", network)
+	}
+	if address == "" {
+		return nil, fmt.Errorf("missing socket address in %q", addr)
+	}
+	return &socketWriter{network: network, addr: address, opts: opts}, nil
+}
+
+// write sends the lines, in batches of opts.flushLines when set.
+func (s *socketWriter) write(lines []string) error {
+	batch := len(lines)
+	if s.opts.flushLines > 0 {
+		batch = s.opts.flushLines
+	}
+	for len(lines) > 0 {
+		n := batch
+		if n > len(lines) {
+			n = len(lines)
+		}
+		if err := s.send(lines[:n]); err != nil {
+			return err
+		}
+		lines = lines[n:]
+	}
+	return nil
+}
+
+// send writes the lines, connecting again once if the connection dropped.
+// After a partial write, sending resumes at the first line not completely
+// written, so the reader sees no truncated line twice.
+func (s *socketWriter) send(lines []string) error {
+	buf := &bytes.Buffer{}
+	for _, line := range lines {
+		buf.WriteString(line)
+		buf.WriteByte('\n')
+	}
+	payload := buf.Bytes()
+
+	for attempt := 0; ; attempt++ {
+		if s.conn == nil {
+			conn, err := net.DialTimeout(s.network, s.addr, s.opts.dialTimeout)
+			if err != nil {
+				return err
+			}
+			s.conn = conn
+		}
+
+		// As for the fetch, a zero timeout means none.
+		deadline := time.Time{}
+		if s.opts.writeTimeout > 0 {
+			deadline = time.Now().Add(s.opts.writeTimeout)
+		}
+		s.conn.SetWriteDeadline(deadline)
+		n, err := s.conn.Write(payload)
+		if err == nil {
+			return nil
+		}
+		s.conn.Close()
+		s.conn = nil
+		if attempt > 0 {
+			return err
+		}
+		slog.Warn("Socket write failed, connecting again", "addr", s.addr, "written", n, "error", err)
+		payload = payload[bytes.LastIndexByte(payload[:n], '\n')+1:]
+	}
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestNewSocketWriter(t *testing.T) {
+	tests := []struct {
+		addr, network, address string
+		wantErr                bool
+	}{
+		{"unix:///run/telegraf.sock", "unix", "/run/telegraf.sock", false},
+		{"/run/telegraf.sock", "unix", "/run/telegraf.sock", false},
+		{"tcp://127.0.0.1:8094", "tcp", "127.0.0.1:8094", false},
+		{"127.0.0.1:8094", "tcp", "127.0.0.1:8094", false},
+		{"tcp6://[::1]:8094", "tcp6", "[::1]:8094", false},
+		{"udp://127.0.0.1:8094", "", "", true},
+		{"unix://", "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.addr, func(t *testing.T) {
+			w, err := newSocketWriter(tt.addr, outputOptions{})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("newSocketWriter() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && (w.network != tt.network || w.addr != tt.address) {
+				t.Errorf("newSocketWriter() = %s %s, want %s %s", w.network, w.addr, tt.network, tt.address)
+			}
+		})
+	}
+}
+
+func TestSocketWriterTimeout(t *testing.T) {
+	tests := []struct {
+		name         string
+		writeTimeout time.Duration
+	}{
+		{"no timeout", 0},
+		{"timeout", time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			listener, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer listener.Close()
+			received := make(chan string, 1)
+			go func() {
+				conn, err := listener.Accept()
+				if err != nil {
+					return
+				}
+				defer conn.Close()
+				data, _ := io.ReadAll(conn)
+				received <- string(data)
+			}()
+
+			w, err := newSocketWriter("tcp://"+listener.Addr().String(), outputOptions{dialTimeout: time.Second, writeTimeout: tt.writeTimeout})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := w.write([]string{"ckc,host=node1 Count=1i 1"}); err != nil {
+				t.Fatalf("write() error = %v", err)
+			}
+			w.conn.Close()
+			select {
+			case got := <-received:
+				if got != "ckc,host=node1 Count=1i 1\n" {
+					t.Errorf("received %q, want the line", got)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("timed out waiting for the line")
+			}
+		})
+	}
+}
+
+// TestSocketWriterUnix has the listener drop the connection after the first
+// scrape, so the second one is written after connecting again.
+func TestSocketWriterUnix(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "telegraf.sock")
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	dropped := make(chan struct{})
+	received := make(chan []string, 1)
+	go func() {
+		lines := []string{}
+		for i := 0; i < 2; i++ {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			scanner := bufio.NewScanner(conn)
+			for scanner.Scan() {
+				lines = append(lines, scanner.Text())
+				if i == 0 && len(lines) == 2 {
+					conn.Close()
+					close(dropped)
+					break
+				}
+			}
+			conn.Close()
+		}
+		received <- lines
+	}()
+
+	w, err := newSocketWriter("unix://"+path, outputOptions{dialTimeout: time.Second, writeTimeout: time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.write([]string{"ckc,host=node1 Count=1i 1", "ckc,host=node2 Count=2i 1"}); err != nil {
+		t.Fatal(err)
+	}
+	<-dropped
+	if err := w.write([]string{"ckc,host=node1 Count=3i 2"}); err != nil {
+		t.Fatal(err)
+	}
+	w.conn.Close()
+
+	want := []string{"ckc,host=node1 Count=1i 1", "ckc,host=node2 Count=2i 1", "ckc,host=node1 Count=3i 2"}
+	select {
+	case got := <-received:
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("received %q, want %q", got, want)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the lines")
+	}
+}
+
+func TestSocketFlag(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "telegraf.sock")
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	received := make(chan string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		data, _ := io.ReadAll(conn)
+		received <- string(data)
+	}()
+
+	code, stdout, stderr := runMain(t, "--stderr", "--measurement", "ckc", "--jolokia", stubJolokia(t), "--socket", "unix://"+path)
+	if code != 0 {
+		t.Fatalf("exit code = %d, stderr: %s", code, stderr)
+	}
+	if stdout != "" {
+		t.Errorf("stdout = %q, want nothing printed", stdout)
+	}
+	select {
+	case data := <-received:
+		if got := collected(data); len(got) != len(tableMetrics) {
+			t.Errorf("received %q, want the %d table metrics", got, len(tableMetrics))
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the lines")
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"log/slog"
+	"net"
+	"strings"
+)
+
+// statsdPacketSize keeps the packets within the MTU of most networks.
+const statsdPacketSize = 1432
+
+// sendStatsd sends the lines to addr over UDP, as many per packet as fit.
+// Packets that fail to send are logged and the others are still sent.
+func sendStatsd(addr string, lines []string) error {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	send := func(packet []string) {
+		if len(packet) == 0 {
+			return
+		}
+		if _, err := conn.Write([]byte(strings.Join(packet, "\n"))); err != nil {
+			slog.Error("Could not send statsd packet", "addr", addr, "lines", len(packet), "error", err)
+		}
+	}
+
+	packet := []string{}
+	size := 0
+	for _, line := range lines {
+		if size+len(line)+1 > statsdPacketSize {
+			send(packet)
+			packet, size = nil, 0
+		}
+		packet = append(packet, line)
+		size += len(line) + 1
+	}
+	send(packet)
+	return nil
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// listenStatsd runs a UDP listener, returning its address and a function
+// reading the packets received until none arrives for a while.
+func listenStatsd(t *testing.T) (string, func() []string) {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	read := func() []string {
+		packets := []string{}
+		buf := make([]byte, 65536)
+		for {
+			conn.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+			n, _, err := conn.ReadFrom(buf)
+			if err != nil {
+				return packets
+			}
+			packets = append(packets, string(buf[:n]))
+		}
+	}
+	return conn.LocalAddr().String(), read
+}
+
+func TestSendStatsd(t *testing.T) {
+	many := []string{}
+	for i := 0; i < 100; i++ {
+		many = append(many, fmt.Sprintf("cassandra.columnfamily.ReadLatency.Count:%d|g|#cf:users,host:node1,keyspace:ks%d", i, i))
+	}
+	tests := []struct {
+		name        string
+		lines       []string
+		wantPackets int
+	}{
+		{"none", nil, 0},
+		{"one packet", many[:3], 1},
+		{"batched", many, 6},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addr, read := listenStatsd(t)
+			if err := sendStatsd(addr, tt.lines); err != nil {
+				t.Fatal(err)
+			}
+			packets := read()
+			if len(packets) != tt.wantPackets {
+				t.Errorf("got %d packets, want %d", len(packets), tt.wantPackets)
+			}
+			got := []string{}
+			for _, packet := range packets {
+				if len(packet) > statsdPacketSize {
+					t.Errorf("packet of %d bytes, above %d", len(packet), statsdPacketSize)
+				}
+				got = append(got, strings.Split(packet, "\n")...)
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.lines, "\n") {
+				t.Errorf("received %q, want %q", got, tt.lines)
+			}
+		})
+	}
+}
+
+func TestSendStatsdUnreachable(t *testing.T) {
+	addr, _ := listenStatsd(t)
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed := conn.LocalAddr().String()
+	conn.Close()
+
+	lines := []string{"a:1|g|#", "b:2|g|#"}
+	for _, addr := range []string{closed, addr} {
+		if err := sendStatsd(addr, lines); err != nil {
+			t.Errorf("sendStatsd(%s) error = %v, want the failure logged only", addr, err)
+		}
+	}
+}
+
+func TestStatsdOutput(t *testing.T) {
+	addr, read := listenStatsd(t)
+	code, stdout, stderr := runMain(t, "--stderr", "--jolokia", stubJolokia(t), "--output-format", "statsd", "--statsd-addr", addr)
+	if code != 0 {
+		t.Fatalf("exit code = %d, stderr: %s", code, stderr)
+	}
+	if stdout != "" {
+		t.Errorf("stdout = %q, want nothing printed", stdout)
+	}
+	got := []string{}
+	for _, packet := range read() {
+		for _, line := range strings.Split(packet, "\n") {
+			if name, _, _ := strings.Cut(line, "|"); strings.HasPrefix(name, "cassandra.columnfamily.") {
+				got = append(got, name)
+			}
+		}
+	}
+	if want := len(tableMetrics); len(got) != want {
+		t.Fatalf("received %q, want %d table gauges", got, want)
+	}
+	if !strings.Contains(strings.Join(got, "\n"), "cassandra.columnfamily.ReadLatency.Count:1") {
+		t.Errorf("received %q, want the ReadLatency counts", got)
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"sync"
+
+	"github.com/CrossEngage/cassandra-keyspaces-checker/internal/checker"
+	"gopkg.in/yaml.v3"
+)
+
+// target is an entry of --targets-file.
+type target struct {
+	URL  string            `yaml:"url"`
+	Tags map[string]string `yaml:"tags"`
+}
+
+// targetSet holds the jolokia agents read from --targets-file, keeping the
+// last ones read successfully when the file goes missing or bad.
+type targetSet struct {
+	mu       sync.Mutex
+	filename string
+	urls     []*url.URL
+	tags     map[string]map[string]string
+}
+
+// readTargetsFile parses a YAML or JSON list of targets, each with a url and
+// optional tags.
+func readTargetsFile(filename string) ([]*url.URL, map[string]map[string]string, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	targets := []target{}
+	if err := yaml.Unmarshal(data, &targets); err != nil {
+		return nil, nil, fmt.Errorf("could not parse %s: %s", filename, err)
+	}
+
+	urls := []*url.URL{}
+	tags := map[string]map[string]string{}
+	for _, t := range targets {
+		u, err := url.Parse(t.URL)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return nil, nil, fmt.Errorf("invalid target url %q in %s", t.URL, filename)
+		}
+		urls = append(urls, u)
+		if len(t.Tags) > 0 {
+			tags[u.String()] = t.Tags
+		}
+	}
+	if len(urls) == 0 {
+		return nil, nil, fmt.Errorf("no targets in %s", filename)
+	}
+	return urls, tags, nil
+}
+
+// reload re-reads the targets file. On error, the previous targets are kept.
+func (t *targetSet) reload() error {
+	urls, tags, err := readTargetsFile(t.filename)
+	if err != nil {
+		return err
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.urls, t.tags = urls, tags
+	return nil
+}
+
+// apply sets the targets as the agents of cfg, their tags taking precedence
+// over the discovered ones.
+func (t *targetSet) apply(cfg checker.Config) checker.Config {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	nodeTags := map[string]map[string]string{}
+	for _, u := range t.urls {
+		tags := map[string]string{}
+		for key, value := range cfg.NodeTags[u.String()] {
+			tags[key] = value
+		}
+		for key, value := range t.tags[u.String()] {
+			tags[key] = value
+		}
+		nodeTags[u.String()] = tags
+	}
+	cfg.JolokiaURLs = t.urls
+	cfg.NodeTags = nodeTags
+	return cfg
+}
+
+// refresh re-reads the targets file before a scrape, logging errors.
+func (t *targetSet) refresh() {
+	if err := t.reload(); err != nil {
+		slog.Error("Could not read the targets file, keeping the previous targets", "file", t.filename, "error", err)
+	}
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/CrossEngage/cassandra-keyspaces-checker/internal/checker"
+)
+
+func TestReadTargetsFile(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		wantURLs []string
+		wantTags map[string]map[string]string
+		wantErr  bool
+	}{
+		{"yaml", "- url: http://node1:8778/jolokia\n  tags:\n    rack: r1\n- url: http://node2:8778/jolokia\n",
+			[]string{"http://node1:8778/jolokia", "http://node2:8778/jolokia"},
+			map[string]map[string]string{"http://node1:8778/jolokia": {"rack": "r1"}}, false},
+		{"json", `[{"url": "http://node1:8778/jolokia", "tags": {"rack": "r1"}}]`,
+			[]string{"http://node1:8778/jolokia"},
+			map[string]map[string]string{"http://node1:8778/jolokia": {"rack": "r1"}}, false},
+		{"empty", "[]", nil, nil, true},
+		{"malformed", "- url: [", nil, nil, true},
+		{"invalid url", "- url: node1:8778\n", nil, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filename := filepath.Join(t.TempDir(), "targets.yaml")
+			if err := os.WriteFile(filename, []byte(tt.content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			urls, tags, err := readTargetsFile(filename)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("readTargetsFile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			got := []string{}
+			for _, u := range urls {
+				got = append(got, u.String())
+			}
+			if !reflect.DeepEqual(got, tt.wantURLs) {
+				t.Errorf("urls = %q, want %q", got, tt.wantURLs)
+			}
+			if !reflect.DeepEqual(tags, tt.wantTags) {
+				t.Errorf("tags = %v, want %v", tags, tt.wantTags)
+			}
+		})
+	}
+}
+
+func TestTargetSetKeepsLastKnownGood(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "targets.yaml")
+	write := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(filename, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	urls := func(set *targetSet) []string {
+		got := []string{}
+		for _, u := range set.apply(checker.Config{}).JolokiaURLs {
+			got = append(got, u.String())
+		}
+		return got
+	}
+
+	write("- url: http://node1:8778/jolokia\n")
+	set := &targetSet{filename: filename}
+	if err := set.reload(); err != nil {
+		t.Fatal(err)
+	}
+	write("- url: http://node1:8778/jolokia\n- url: http://node2:8778/jolokia\n")
+	set.refresh()
+	want := []string{"http://node1:8778/jolokia", "http://node2:8778/jolokia"}
+	if got := urls(set); !reflect.DeepEqual(got, want) {
+		t.Fatalf("after adding a target, urls = %q, want %q", got, want)
+	}
+
+	write("- url: [")
+	set.refresh()
+	if got := urls(set); !reflect.DeepEqual(got, want) {
+		t.Errorf("after a malformed file, urls = %q, want the last good %q", got, want)
+	}
+	os.Remove(filename)
+	set.refresh()
+	if got := urls(set); !reflect.DeepEqual(got, want) {
+		t.Errorf("after removing the file, urls = %q, want the last good %q", got, want)
+	}
+}
+
+// countingAgent runs a jolokia agent counting the scrapes it serves.
+func countingAgent(t *testing.T) (string, *atomic.Int32) {
+	t.Helper()
+	scrapes := &atomic.Int32{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		scrapes.Add(1)
+		io.WriteString(w, `{"status": 200, "timestamp": 1700000000, "value": {"org.apache.cassandra.metrics:keyspace=app,name=ReadLatency,scope=users,type=ColumnFamily": {"Count": 1}}}`)
+	}))
+	t.Cleanup(server.Close)
+	return server.URL + "/jolokia", scrapes
+}
+
+// waitFor polls cond until it holds, failing the test after a while.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestTargetAddedMidRun(t *testing.T) {
+	node1, scrapes1 := countingAgent(t)
+	node2, scrapes2 := countingAgent(t)
+	filename := filepath.Join(t.TempDir(), "targets.yaml")
+	if err := os.WriteFile(filename, []byte("- url: "+node1+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	startMain(t, &stdout, &stderr, "--stderr", "--targets-file", filename, "--interval", "100ms")
+	waitFor(t, "a scrape of the first target", func() bool { return scrapes1.Load() > 0 })
+	if scrapes2.Load() != 0 {
+		t.Fatal("the second target was scraped before being added")
+	}
+
+	if err := os.WriteFile(filename, []byte("- url: "+node1+"\n- url: "+node2+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "a scrape of the added target", func() bool { return scrapes2.Load() > 0 })
+}